@@ -61,12 +61,20 @@ func (br *Branch) Add(n int, sls ...interface{}) error {
 		return err
 	}
 
+	if n < 0 {
+		// appending can use the spare capacity of the siblings slice
+		for _, s := range sls {
+			if b, ok := s.(*Branch); ok {
+				b.parent = br
+			}
+		}
+		br.siblings = append(br.siblings, sls...)
+		return nil
+	}
+
 	lbrs := len(br.siblings)
 	lsls := len(sls)
 	l := lbrs + lsls
-	if n < 0 {
-		n = lbrs
-	}
 
 	siblings := make([]interface{}, l)
 	for i := 0; i < lbrs; i++ {
@@ -166,7 +174,7 @@ func (br *Branch) Remove(n int) (interface{}, error) {
 		return nil, ErrNoSiblings
 	}
 
-	if n < 0 {
+	if n < 0 || n == l-1 {
 		sblg := br.siblings[l-1]
 		br.siblings = br.siblings[:l-1]
 		return sblg, nil
@@ -0,0 +1,186 @@
+//
+// highlight.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// highlight splits source code into tokens for syntax highlighting.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package highlight splits source code into classified tokens, so it can be
+// rendered with syntax highlighting.
+package highlight
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Kind classifies a token.
+type Kind int
+
+// Kinds of tokens.
+const (
+	Text Kind = iota
+	Comment
+	Keyword
+	Type
+	Builtin
+	Literal
+	String
+	Number
+	Operator
+	Variable
+	Key
+	Meta
+	Heading
+	Emphasis
+	Link
+)
+
+var kindNames = []string{
+	"text", "comment", "keyword", "type", "builtin", "literal", "string",
+	"number", "operator", "variable", "key", "meta", "heading", "emphasis",
+	"link",
+}
+
+// styles holds the CSS declarations for each kind of token.
+var styles = []string{
+	Comment:  "color: #6a737d; font-style: italic;",
+	Keyword:  "color: #d73a49; font-weight: bold;",
+	Type:     "color: #6f42c1;",
+	Builtin:  "color: #005cc5;",
+	Literal:  "color: #005cc5;",
+	String:   "color: #032f62;",
+	Number:   "color: #005cc5;",
+	Operator: "color: #d73a49;",
+	Variable: "color: #e36209;",
+	Key:      "color: #22863a;",
+	Meta:     "color: #735c0f;",
+	Heading:  "color: #005cc5; font-weight: bold;",
+	Emphasis: "font-style: italic;",
+	Link:     "color: #032f62; text-decoration: underline;",
+}
+
+// Token holds a piece of source code and its kind.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Lexer splits source code into tokens.
+type Lexer interface {
+	// Tokens returns the tokens for 'src'. Concatenating their texts gives
+	// 'src' again.
+	Tokens(src string) []Token
+}
+
+// lexers holds the lexers by language name.
+var lexers = map[string]Lexer{
+	"c":        cLexer,
+	"h":        cLexer,
+	"go":       goLexer,
+	"golang":   goLexer,
+	"json":     jsonLexer,
+	"markdown": mdLexer{},
+	"md":       mdLexer{},
+	"py":       pythonLexer,
+	"python":   pythonLexer,
+	"python3":  pythonLexer,
+	"bash":     shellLexer,
+	"sh":       shellLexer,
+	"shell":    shellLexer,
+	"zsh":      shellLexer,
+	"yaml":     yamlLexer,
+	"yml":      yamlLexer,
+}
+
+// Class returns the name of the CSS class for tokens of kind 'k'.
+func (k Kind) Class() string {
+	return "tok-" + k.String()
+}
+
+// String returns the name of kind 'k'.
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("kind%d", int(k))
+	}
+	return kindNames[k]
+}
+
+// Lookup returns the lexer for the language 'lang'. When there is no lexer
+// for it, nil will be returned.
+func Lookup(lang string) Lexer {
+	return lexers[strings.ToLower(lang)]
+}
+
+// WriteCSS writes a style sheet holding a rule for the CSS class of each kind
+// of token to 'w'. When an error occured, it will be returned.
+func WriteCSS(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "/* md2html syntax highlighting */\n"); err != nil {
+		return err
+	}
+	for k, style := range styles {
+		if len(style) <= 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "pre code .%s { %s }\n", Kind(k).Class(),
+			style); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanner collects the tokens for 'src'. 'pos' is the position in 'src'
+// up to which tokens have been collected.
+type scanner struct {
+	src  string
+	pos  int
+	toks []Token
+}
+
+// emit adds a token of kind 'k' holding the next 'n' bytes of the source. It
+// is merged with the last token when that is of the same kind.
+func (sc *scanner) emit(k Kind, n int) {
+	if n <= 0 {
+		return
+	}
+	end := sc.pos + n
+	if l := len(sc.toks); l > 0 && sc.toks[l-1].Kind == k {
+		last := &sc.toks[l-1]
+		last.Text = sc.src[sc.pos-len(last.Text) : end]
+	} else {
+		sc.toks = append(sc.toks, Token{Kind: k, Text: sc.src[sc.pos:end]})
+	}
+	sc.pos = end
+}
+
+// rest returns the part of the source without tokens.
+func (sc *scanner) rest() string {
+	return sc.src[sc.pos:]
+}
@@ -0,0 +1,129 @@
+//
+// highlight_test.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// tests for the highlight package.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+// tokenString returns a string holding all tokens in 'ts' that are not plain
+// text, in the format kind:"text".
+func tokenString(ts []Token) string {
+	s := []string{}
+	for _, t := range ts {
+		if t.Kind != Text {
+			s = append(s, t.Kind.String()+":"+t.Text)
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		lang string
+		src  string
+		want string
+	}{
+		{lang: "go",
+			src:  "// c\nfunc f(s string) int {\n\treturn len(`a\nb`) + 0x1F // x\n}\n",
+			want: "comment:// c keyword:func type:string type:int keyword:return builtin:len string:`a\nb` operator:+ number:0x1F comment:// x"},
+		{lang: "go", src: "x := \"a\\\"b\" + 'c' /* d\ne */", want: "operator::= string:\"a\\\"b\" operator:+ string:'c' comment:/* d\ne */"},
+		{lang: "golang", src: "v2 := 1.5e-3", want: "operator::= number:1.5e-3"},
+		{lang: "sh",
+			src:  "if [ -n \"$X\" ]; then echo ${Y} 'a#b' a#b # c\nfi\n",
+			want: "keyword:if operator:- string:\"$X\" keyword:then builtin:echo variable:${Y} string:'a#b' comment:# c keyword:fi"},
+		{lang: "bash", src: "echo $1 $HOME", want: "builtin:echo variable:$1 variable:$HOME"},
+		{lang: "json",
+			src:  "{\"a\": [1, -2.5e3, true, null], \"b\": \"x\"}",
+			want: "key:\"a\" operator:: number:1 operator:- number:2.5e3 literal:true literal:null key:\"b\" operator:: string:\"x\""},
+		{lang: "yaml",
+			src:  "---\n# c\nkey: value\nlist:\n  - name: x # y\n    ok: true\n",
+			want: "meta:--- comment:# c key:key operator:: key:list operator:: operator:- key:name operator:: comment:# y key:ok operator:: literal:true"},
+		{lang: "yml", src: "url: http://x:80", want: "key:url operator:: operator::// operator:: number:80"},
+		{lang: "python",
+			src:  "@dec\ndef f(x):\n    \"\"\"doc\n    \"\"\"\n    return f\"b\" # c\n",
+			want: "meta:@dec keyword:def operator:: string:\"\"\"doc\n    \"\"\" keyword:return string:f\"b\" comment:# c"},
+		{lang: "py", src: "print(None)", want: "builtin:print literal:None"},
+		{lang: "c",
+			src:  "#include <stdio.h>\nint main(void) { /* c */ return NULL; }\n",
+			want: "meta:#include <stdio.h> type:int type:void comment:/* c */ keyword:return literal:NULL"},
+		{lang: "md",
+			src:  "# T\n\n> - item *em* `code` [l](u)\n```go\nx\n```\n1. a\n",
+			want: "heading:# T operator:> operator:- emphasis:*em* string:`code` link:[l](u) meta:```go string:x meta:``` operator:1."},
+		{lang: "markdown", src: "a \\*b* <http://x>", want: "link:<http://x>"},
+	}
+
+	for _, tst := range tests {
+		lx := Lookup(tst.lang)
+		if lx == nil {
+			t.Fatalf("Lookup(%q) returns nil", tst.lang)
+		}
+		ts := lx.Tokens(tst.src)
+
+		src := ""
+		for _, tk := range ts {
+			src += tk.Text
+		}
+		if src != tst.src {
+			t.Errorf("Tokens(%q) for %s holds:\n%q\nshould be:\n%q\n", tst.src,
+				tst.lang, src, tst.src)
+		}
+
+		if got := tokenString(ts); got != tst.want {
+			t.Errorf("Tokens(%q) for %s generates:\n%q\nshould be:\n%q\n", tst.src,
+				tst.lang, got, tst.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if Lookup("GO") != Lookup("go") {
+		t.Errorf("Lookup(\"GO\") should return the lexer for \"go\"")
+	}
+	if Lookup("cobol") != nil {
+		t.Errorf("Lookup(\"cobol\") should return nil")
+	}
+}
+
+func TestWriteCSS(t *testing.T) {
+	var b strings.Builder
+	if err := WriteCSS(&b); err != nil {
+		t.Fatalf("WriteCSS returns error: %s, should be nil", err)
+	}
+	for k := Comment; k <= Link; k++ {
+		if !strings.Contains(b.String(), "."+k.Class()+" {") {
+			t.Errorf("WriteCSS doesn't write a rule for %q", k.Class())
+		}
+	}
+}
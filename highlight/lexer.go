@@ -0,0 +1,387 @@
+//
+// lexer.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// a generic lexer driven by the lexical rules of a language.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package highlight
+
+import (
+	"strings"
+)
+
+// quote describes a string literal.
+type quote struct {
+	delim     string // delimiter at both ends
+	escapes   bool   // true when '\' escapes the next rune
+	multiline bool   // true when the string may hold line ends
+}
+
+// rules holds the lexical rules of a language.
+type rules struct {
+	lineComments  []string    // starts of comments up to the line end
+	blockComments [][2]string // starts and ends of comments
+	wordComments  bool        // true when comments only start a word
+	quotes        []quote     // string literals, longest delimiters first
+	prefixes      words       // identifiers that can prefix a string literal
+	keywords      words
+	types         words
+	builtins      words
+	literals      words
+	metaLine      string // start of a line holding a directive
+	metaRune      byte   // rune starting a directive word
+	variables     bool   // true when '$' starts a variable
+	keys          bool   // true when strings followed by ':' are keys
+	lineKeys      bool   // true when a line can start with 'key:'
+}
+
+// words is a set of words.
+type words map[string]bool
+
+const operators = "+-*/%=<>!&|^~?:"
+
+var cLexer = &rules{
+	lineComments:  []string{"//"},
+	blockComments: [][2]string{{"/*", "*/"}},
+	quotes:        []quote{{"\"", true, false}, {"'", true, false}},
+	keywords: newWords("auto break case const continue default do else enum",
+		"extern for goto if inline register restrict return sizeof static",
+		"struct switch typedef union volatile while"),
+	types: newWords("bool char double float int long short signed unsigned void",
+		"size_t ssize_t int8_t int16_t int32_t int64_t uint8_t uint16_t",
+		"uint32_t uint64_t FILE"),
+	literals: newWords("NULL true false"),
+	metaLine: "#",
+}
+
+var goLexer = &rules{
+	lineComments:  []string{"//"},
+	blockComments: [][2]string{{"/*", "*/"}},
+	quotes: []quote{{"`", false, true}, {"\"", true, false},
+		{"'", true, false}},
+	keywords: newWords("break case chan const continue default defer else",
+		"fallthrough for func go goto if import interface map package range",
+		"return select struct switch type var"),
+	types: newWords("any bool byte complex64 complex128 error float32 float64",
+		"int int8 int16 int32 int64 rune string uint uint8 uint16 uint32",
+		"uint64 uintptr"),
+	builtins: newWords("append cap clear close complex copy delete imag len",
+		"make max min new panic print println real recover"),
+	literals: newWords("true false nil iota"),
+}
+
+var jsonLexer = &rules{
+	quotes:   []quote{{"\"", true, false}},
+	literals: newWords("true false null"),
+	keys:     true,
+}
+
+var pythonLexer = &rules{
+	lineComments: []string{"#"},
+	quotes: []quote{{"\"\"\"", true, true}, {"'''", true, true},
+		{"\"", true, false}, {"'", true, false}},
+	prefixes: newWords("b br f fr r rb rf u B BR F FR R RB RF U"),
+	keywords: newWords("and as assert async await break class continue def",
+		"del elif else except finally for from global if import in is lambda",
+		"nonlocal not or pass raise return try while with yield match case"),
+	builtins: newWords("abs all any bool bytes callable chr dict dir divmod",
+		"enumerate filter float format frozenset getattr hasattr hash help",
+		"hex id input int isinstance issubclass iter len list map max min",
+		"next object oct open ord pow print property range repr reversed",
+		"round set setattr slice sorted str sum super tuple type vars zip",
+		"self cls"),
+	literals: newWords("True False None"),
+	metaRune: '@',
+}
+
+var shellLexer = &rules{
+	lineComments: []string{"#"},
+	wordComments: true,
+	quotes:       []quote{{"\"", true, true}, {"'", false, true}},
+	keywords: newWords("case do done elif else esac fi for function if in",
+		"select then time until while"),
+	builtins: newWords("alias bg break cd command continue declare echo eval",
+		"exec exit export false fg getopts hash jobs kill let local popd",
+		"printf pushd pwd read readonly return set shift source test trap",
+		"true type ulimit umask unalias unset wait"),
+	variables: true,
+}
+
+var yamlLexer = &rules{
+	lineComments: []string{"#"},
+	wordComments: true,
+	quotes:       []quote{{"\"", true, true}, {"'", false, true}},
+	literals: newWords("true false yes no on off null True False Yes No On Off",
+		"Null TRUE FALSE NULL"),
+	keys:     true,
+	lineKeys: true,
+}
+
+// newWords returns a set holding all space separated words in 'lists'.
+func newWords(lists ...string) words {
+	w := words{}
+	for _, l := range lists {
+		for _, f := range strings.Fields(l) {
+			w[f] = true
+		}
+	}
+	return w
+}
+
+// Tokens returns the tokens for 'src' according to the rules.
+func (r *rules) Tokens(src string) []Token {
+	sc := &scanner{src: src}
+	lineStart := true // only white space since the last line end
+
+	for sc.pos < len(src) {
+		c := src[sc.pos]
+		switch {
+		case c == '\n':
+			sc.emit(Text, 1)
+			lineStart = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			sc.emit(Text, 1)
+			continue
+		}
+
+		k, n := r.next(sc, lineStart)
+		sc.emit(k, n)
+		if !(r.lineKeys && k == Operator && c == '-') {
+			// a YAML sequence entry may still be followed by a key
+			lineStart = false
+		}
+	}
+
+	return sc.toks
+}
+
+// next returns the kind and length of the token starting at the position of
+// scanner 'sc'. 'lineStart' is true when it is the first token in a line.
+func (r *rules) next(sc *scanner, lineStart bool) (Kind, int) {
+	s := sc.rest()
+	c := s[0]
+
+	if lineStart && len(r.metaLine) > 0 && strings.HasPrefix(s, r.metaLine) {
+		return Meta, lineLen(s)
+	}
+
+	if lineStart && r.lineKeys {
+		if k, n := yamlLine(s); n > 0 {
+			return k, n
+		}
+	}
+
+	for _, bc := range r.blockComments {
+		if strings.HasPrefix(s, bc[0]) {
+			if i := strings.Index(s[len(bc[0]):], bc[1]); i >= 0 {
+				return Comment, len(bc[0]) + i + len(bc[1])
+			}
+			return Comment, len(s)
+		}
+	}
+
+	for _, lc := range r.lineComments {
+		if strings.HasPrefix(s, lc) && (!r.wordComments || sc.pos == 0 ||
+			isSpace(sc.src[sc.pos-1])) {
+			return Comment, lineLen(s)
+		}
+	}
+
+	if n := r.quoted(s); n > 0 {
+		if r.keys && strings.HasPrefix(strings.TrimLeft(s[n:], " \t"), ":") {
+			return Key, n
+		}
+		return String, n
+	}
+
+	switch {
+	case r.variables && c == '$' && len(s) > 1:
+		return Variable, variableLen(s)
+
+	case r.metaRune != 0 && c == r.metaRune && len(s) > 1 && isIdentStart(s[1]):
+		return Meta, 1 + identLen(s[1:])
+
+	case isDigit(c) || c == '.' && len(s) > 1 && isDigit(s[1]):
+		if sc.pos > 0 && isIdent(sc.src[sc.pos-1]) {
+			return Text, identLen(s)
+		}
+		return Number, numberLen(s)
+
+	case isIdentStart(c):
+		n := identLen(s)
+		w := s[:n]
+		if r.prefixes[w] {
+			if m := r.quoted(s[n:]); m > 0 {
+				return String, n + m
+			}
+		}
+		switch {
+		case r.keywords[w]:
+			return Keyword, n
+		case r.types[w]:
+			return Type, n
+		case r.builtins[w]:
+			return Builtin, n
+		case r.literals[w]:
+			return Literal, n
+		}
+		return Text, n
+
+	case strings.IndexByte(operators, c) >= 0:
+		n := 1
+		for n < len(s) && strings.IndexByte(operators, s[n]) >= 0 {
+			n++
+		}
+		return Operator, n
+	}
+
+	return Text, 1
+}
+
+// quoted returns the length of the string literal at the start of 's', or 0
+// when there is none.
+func (r *rules) quoted(s string) int {
+	for _, q := range r.quotes {
+		if !strings.HasPrefix(s, q.delim) {
+			continue
+		}
+		i := len(q.delim)
+		for i < len(s) {
+			switch {
+			case q.escapes && s[i] == '\\':
+				i += 2
+			case strings.HasPrefix(s[i:], q.delim):
+				return i + len(q.delim)
+			case s[i] == '\n' && !q.multiline:
+				return i // unterminated
+			default:
+				i++
+			}
+		}
+		return len(s)
+	}
+	return 0
+}
+
+// yamlLine returns the kind and length of the token at the start of the YAML
+// line 's', when it is a document marker or a key.
+func yamlLine(s string) (Kind, int) {
+	if strings.HasPrefix(s, "---") || strings.HasPrefix(s, "...") {
+		return Meta, lineLen(s)
+	}
+	if s[0] == '-' && len(s) > 1 && isSpace(s[1]) {
+		return Operator, 1
+	}
+	if s[0] == '#' || s[0] == '"' || s[0] == '\'' {
+		return Text, 0
+	}
+
+	l := lineLen(s)
+	for i := 0; i < l; i++ {
+		switch s[i] {
+		case '#':
+			return Text, 0
+		case ':':
+			if i+1 >= l || isSpace(s[i+1]) {
+				return Key, i
+			}
+		}
+	}
+	return Text, 0
+}
+
+// identLen returns the length of the identifier at the start of 's'.
+func identLen(s string) int {
+	n := 0
+	for n < len(s) && isIdent(s[n]) {
+		n++
+	}
+	return n
+}
+
+// lineLen returns the length of 's' up to, but not including, its first line
+// end.
+func lineLen(s string) int {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return i
+	}
+	return len(s)
+}
+
+// numberLen returns the length of the number at the start of 's'.
+func numberLen(s string) int {
+	n := 1
+	for n < len(s) {
+		c := s[n]
+		switch {
+		case isIdent(c) || c == '.':
+			n++
+		case (c == '+' || c == '-') && (s[n-1] == 'e' || s[n-1] == 'E') &&
+			!strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X"):
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// variableLen returns the length of the shell variable at the start of 's',
+// which starts with a '$'.
+func variableLen(s string) int {
+	switch {
+	case s[1] == '{':
+		if i := strings.IndexByte(s, '}'); i > 0 {
+			return i + 1
+		}
+		return lineLen(s)
+	case isIdent(s[1]):
+		return 1 + identLen(s[1:])
+	case strings.IndexByte("#?$!@*-", s[1]) >= 0:
+		return 2
+	}
+	return 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
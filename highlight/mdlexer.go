@@ -0,0 +1,194 @@
+//
+// mdlexer.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// a lexer for mark down text.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package highlight
+
+import (
+	"strings"
+)
+
+// mdLexer splits mark down text into tokens. Headers, fence lines and the
+// markers for lists and block quotes are recognised per line, code spans,
+// emphasis and links within a line.
+type mdLexer struct{}
+
+// Tokens returns the tokens for the mark down text 'src'.
+func (mdLexer) Tokens(src string) []Token {
+	sc := &scanner{src: src}
+	fence := ""
+
+	for sc.pos < len(src) {
+		l := lineLen(sc.rest())
+		line := sc.rest()[:l]
+		trimmed := strings.TrimLeft(line, " ")
+		indnt := len(line) - len(trimmed)
+
+		switch {
+		case len(fence) > 0:
+			if indnt < 4 && strings.HasPrefix(trimmed, fence) &&
+				len(strings.Trim(trimmed, fence[:1]+" ")) == 0 {
+				sc.emit(Meta, l)
+				fence = ""
+			} else {
+				sc.emit(String, l)
+			}
+
+		case indnt < 4 && (strings.HasPrefix(trimmed, "```") ||
+			strings.HasPrefix(trimmed, "~~~")):
+			n := 0
+			for n < len(trimmed) && trimmed[n] == trimmed[0] {
+				n++
+			}
+			fence = trimmed[:n]
+			sc.emit(Meta, l)
+
+		case indnt < 4 && headerLevel(trimmed) > 0:
+			sc.emit(Heading, l)
+
+		default:
+			sc.emit(Text, indnt)
+			rest := trimmed
+			for n := blockMarker(rest); n > 0; n = blockMarker(rest) {
+				sp := len(rest[n:]) - len(strings.TrimLeft(rest[n:], " "))
+				sc.emit(Operator, n)
+				sc.emit(Text, sp)
+				rest = rest[n+sp:]
+			}
+			mdInline(sc, len(rest))
+		}
+
+		if sc.pos < len(src) {
+			sc.emit(Text, 1) // line end
+		}
+	}
+
+	return sc.toks
+}
+
+// blockMarker returns the length of the block quote or list item marker at
+// the start of 's', or 0 when there is none.
+func blockMarker(s string) int {
+	switch {
+	case len(s) <= 0:
+		return 0
+	case s[0] == '>':
+		return 1
+	case strings.IndexByte("-*+", s[0]) >= 0 && (len(s) == 1 || s[1] == ' '):
+		return 1
+	}
+	n := 0
+	for n < len(s) && n < 9 && isDigit(s[n]) {
+		n++
+	}
+	if n > 0 && n < len(s) && (s[n] == '.' || s[n] == ')') &&
+		(n+1 == len(s) || s[n+1] == ' ') {
+		return n + 1
+	}
+	return 0
+}
+
+// headerLevel returns the level of the header in 's', or 0 when 's' isn't a
+// header.
+func headerLevel(s string) int {
+	n := 0
+	for n < len(s) && s[n] == '#' {
+		n++
+	}
+	if n == 0 || n > 6 || n < len(s) && s[n] != ' ' {
+		return 0
+	}
+	return n
+}
+
+// mdInline emits the tokens for the next 'l' bytes of scanner 'sc', which
+// hold the inline content of a line.
+func mdInline(sc *scanner, l int) {
+	end := sc.pos + l
+	for sc.pos < end {
+		s := sc.src[sc.pos:end]
+		switch c := s[0]; {
+		case c == '\\' && len(s) > 1:
+			sc.emit(Text, 2)
+
+		case c == '`':
+			n := strings.IndexByte(s[1:], '`')
+			if n < 0 {
+				sc.emit(Text, 1)
+			} else {
+				sc.emit(String, n+2)
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			run := 1
+			for run < len(s) && s[run] == c {
+				run++
+			}
+			if i := strings.Index(s[run:], s[:run]); i > 0 {
+				sc.emit(Emphasis, i+2*run)
+			} else {
+				sc.emit(Text, run)
+			}
+
+		case c == '[' || c == '!' && strings.HasPrefix(s, "!["):
+			if n := linkLen(s); n > 0 {
+				sc.emit(Link, n)
+			} else {
+				sc.emit(Text, 1)
+			}
+
+		case c == '<':
+			if n := strings.IndexByte(s, '>'); n > 0 &&
+				strings.Contains(s[:n], ":") && !strings.Contains(s[:n], " ") {
+				sc.emit(Link, n+1)
+			} else {
+				sc.emit(Text, 1)
+			}
+
+		default:
+			sc.emit(Text, 1)
+		}
+	}
+}
+
+// linkLen returns the length of the link or image at the start of 's', or 0
+// when there is none.
+func linkLen(s string) int {
+	i := strings.Index(s, "](")
+	if i < 0 || strings.IndexByte(s[:i], ']') >= 0 {
+		return 0
+	}
+	j := strings.IndexByte(s[i:], ')')
+	if j < 0 {
+		return 0
+	}
+	return i + j + 1
+}
@@ -0,0 +1,215 @@
+//
+// autolink.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// functions for recognising autolinks and bare URLs.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"strings"
+)
+
+// Autolinks translates autolinks, an absolute URL or an email address
+// enclosed by '<' and '>', to their html equivalents. When the sanitizer
+// rejects the URL, only the text is kept.
+func (in *Inliner) Autolinks(s string) string {
+	for i := strings.Index(s, "<"); i >= 0; {
+		if n := DestLen(s, i); n > 0 {
+			// a link destination between '<' and '>' isn't an autolink
+			i += n - 1
+		} else if n := strings.IndexByte(s[i:], '>'); n > 0 {
+			u, href := s[i+1:i+n], ""
+			switch {
+			case IsAbsURL(u):
+				href = u
+			case IsEmail(u):
+				href = "mailto:" + u
+			}
+			if len(href) > 0 {
+				a := EscapeHTML(u)
+				if in.Sn.URL(href) {
+					a = "<a href=\"" + urlAttr(href) + "\">" + a + "</a>"
+				}
+				return s[:i] + in.keep(a) + in.Autolinks(s[i+n+1:])
+			}
+		}
+		k := strings.Index(s[i+1:], "<")
+		if k < 0 {
+			break
+		}
+		i += k + 1
+	}
+	return s
+}
+
+// BareURLs translates URLs starting with 'http://', 'https://' or 'www.' to
+// links. They must be at the start of 's', or follow a white space or one of
+// the runes '*', '_', '~' or '('.
+func (in *Inliner) BareURLs(s string) string {
+	var b strings.Builder
+	last := 0 // end of the text copied to 'b'
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c != 'h' && c != 'w' ||
+			i > 0 && i != last && strings.IndexByte(" \t\n*_~(", s[i-1]) < 0 {
+			continue
+		}
+		n := BareURLLen(s[i:])
+		if n <= 0 {
+			continue
+		}
+		u, href := s[i:i+n], s[i:i+n]
+		if hasPrefixFold(u, "www.") {
+			href = "http://" + u
+		}
+		b.WriteString(s[last:i])
+		b.WriteString(in.keep("<a href=\"" + urlAttr(href) + "\">" + EscapeHTML(u) + "</a>"))
+		i += n - 1
+		last = i + 1
+	}
+	if last <= 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// BareURLLen returns the length of the URL starting with 'http://',
+// 'https://' or 'www.' at the start of 's', or 0 when there is none. The URL
+// ends at a white space or a '<'. Trailing punctuation, unbalanced closing
+// parentheses and an entity reference at its end are no part of it.
+func BareURLLen(s string) int {
+	start := 0
+	switch {
+	case hasPrefixFold(s, "https://"):
+		start = 8
+	case hasPrefixFold(s, "http://"):
+		start = 7
+	case !hasPrefixFold(s, "www."):
+		return 0
+	}
+
+	n := start
+	for n < len(s) && (isAlnum(s[n]) || strings.IndexByte("_-.", s[n]) >= 0) {
+		n++
+	}
+	if !isDomain(strings.TrimRight(s[start:n], ".")) {
+		return 0
+	}
+	for n < len(s) && s[n] > ' ' && s[n] != '<' && s[n] != 0x7f {
+		n++
+	}
+
+	for u := s[:n]; ; u = s[:n] {
+		switch c := u[len(u)-1]; {
+		case strings.IndexByte("?!.,:*_~'\"", c) >= 0:
+			n--
+		case c == ')' && strings.Count(u, ")") > strings.Count(u, "("):
+			n--
+		case c == ';':
+			i := strings.LastIndexByte(u, '&')
+			if i < 0 || EntityLen(u[i:]) != len(u)-i {
+				return n
+			}
+			n = i
+		default:
+			return n
+		}
+		if n <= start {
+			return 0
+		}
+	}
+}
+
+// hasPrefixFold tests if 's' starts with 'prefix', ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// IsAbsURL tests if 's' is an absolute URL, a scheme followed by a ':' and
+// no white space, control characters, '<' or '>'.
+func IsAbsURL(s string) bool {
+	i := strings.IndexByte(s, ':')
+	if i < 2 || i > 32 || !isAlpha(s[0]) {
+		return false
+	}
+	for j := 1; j < i; j++ {
+		if !isAlnum(s[j]) && strings.IndexByte("+.-", s[j]) < 0 {
+			return false
+		}
+	}
+	for j := i + 1; j < len(s); j++ {
+		if s[j] <= ' ' || s[j] == '<' || s[j] == '>' || s[j] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// IsEmail tests if 's' is an email address.
+func IsEmail(s string) bool {
+	i := strings.IndexByte(s, '@')
+	if i <= 0 {
+		return false
+	}
+	for j := 0; j < i; j++ {
+		if !isAlnum(s[j]) && strings.IndexByte(".!#$%&'*+/=?^_`{|}~-", s[j]) < 0 {
+			return false
+		}
+	}
+	for _, l := range strings.Split(s[i+1:], ".") {
+		n := len(l)
+		if n <= 0 || n > 63 || l[0] == '-' || l[n-1] == '-' {
+			return false
+		}
+		for j := 0; j < n; j++ {
+			if !isAlnum(l[j]) && l[j] != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isDomain tests if 's' is a valid domain: segments of letters, digits, '_'
+// and '-' separated by periods. There must be at least one period and the
+// last two segments must not hold a '_'.
+func isDomain(s string) bool {
+	sgmnts := strings.Split(s, ".")
+	n := len(sgmnts)
+	if n < 2 {
+		return false
+	}
+	for i, sg := range sgmnts {
+		if len(sg) <= 0 || i >= n-2 && strings.Contains(sg, "_") {
+			return false
+		}
+	}
+	return true
+}
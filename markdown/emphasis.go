@@ -0,0 +1,216 @@
+//
+// emphasis.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// emphasis, strong emphasis and deleted text using delimiter runs.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// delim holds a run of the delimiter runes '*', '_' or '~'. The runs that can
+// open or close emphasis form a doubly linked list, the delimiter stack.
+type delim struct {
+	c        byte     // delimiter rune
+	n        int      // number of runes that aren't used yet
+	orig     int      // original number of runes
+	canOpen  bool     // true if the run can open emphasis
+	canClose bool     // true if the run can close emphasis
+	opening  []string // tags following the run
+	closing  []string // tags preceding the run
+	prev     *delim   // previous run on the delimiter stack
+	next     *delim   // next run on the delimiter stack
+}
+
+// bottomKey is the key for the lower bounds when looking for an opener.
+type bottomKey struct {
+	c       byte
+	mod     int
+	canOpen bool
+}
+
+// StrongEmDel translates mark down strong, emphasis and deleted definitions
+// to their html equivalents. It follows the CommonMark rules for delimiter
+// runs of '*' and '_'. A run of two '~' runes marks deleted text.
+func StrongEmDel(s string) string {
+	texts := []string{} // texts[i] precedes dlms[i]
+	dlms := []*delim{}
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if !isDelim(c) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] == c {
+			j++
+		}
+		texts = append(texts, s[start:i])
+		dlms = append(dlms, newDelim(s, i, j))
+		start, i = j, j
+	}
+	if len(dlms) <= 0 {
+		return s
+	}
+
+	for i := 1; i < len(dlms); i++ {
+		dlms[i-1].next, dlms[i].prev = dlms[i], dlms[i-1]
+	}
+	processEmphasis(dlms[0])
+
+	var b strings.Builder
+	for i, d := range dlms {
+		b.WriteString(texts[i])
+		b.WriteString(strings.Join(d.closing, ""))
+		b.WriteString(strings.Repeat(string(d.c), d.n))
+		b.WriteString(strings.Join(d.opening, ""))
+	}
+	b.WriteString(s[start:])
+	return b.String()
+}
+
+// isDelim tests if 'c' is a delimiter rune.
+func isDelim(c byte) bool {
+	return c == '*' || c == '_' || c == '~'
+}
+
+// newDelim returns a pointer to a new delim for the run of delimiter runes
+// from position 'i' up to 'j' in 's'.
+func newDelim(s string, i, j int) *delim {
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if j < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[j:])
+	}
+
+	left := !unicode.IsSpace(after) &&
+		(!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	right := !unicode.IsSpace(before) &&
+		(!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	d := &delim{c: s[i], n: j - i, orig: j - i, canOpen: left, canClose: right}
+	if d.c == '_' {
+		d.canOpen = left && (!right || isPunct(before))
+		d.canClose = right && (!left || isPunct(after))
+	}
+	return d
+}
+
+// isPunct tests if 'r' is a punctuation rune or a symbol. The mark for
+// stashed html code counts as punctuation.
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r) || r == rune(cMark[0])
+}
+
+// processEmphasis matches the openers and closers on the delimiter stack that
+// starts with 'first', and adds the tags for them.
+func processEmphasis(first *delim) {
+	bottoms := map[bottomKey]*delim{}
+
+	for closer := first; closer != nil; {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		key := bottomKey{closer.c, closer.orig % 3, closer.canOpen}
+		bottom, hasBottom := bottoms[key]
+		opener := closer.prev
+		for opener != nil && !(hasBottom && opener == bottom) &&
+			!isMatch(opener, closer) {
+			opener = opener.prev
+		}
+
+		if opener == nil || hasBottom && opener == bottom {
+			bottoms[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				closer.remove()
+			}
+			closer = next
+			continue
+		}
+
+		use, tg := 1, "em"
+		switch {
+		case closer.c == '~':
+			use, tg = 2, "del"
+		case opener.n >= 2 && closer.n >= 2:
+			use, tg = 2, "strong"
+		}
+		opener.opening = append([]string{"<" + tg + ">"}, opener.opening...)
+		closer.closing = append(closer.closing, "</"+tg+">")
+		opener.n -= use
+		closer.n -= use
+
+		// the runs in between can't be matched anymore
+		opener.next, closer.prev = closer, opener
+
+		if opener.n <= 0 {
+			opener.remove()
+		}
+		if closer.n <= 0 {
+			next := closer.next
+			closer.remove()
+			closer = next
+		}
+	}
+}
+
+// isMatch tests if 'opener' can be matched with 'closer'.
+func isMatch(opener, closer *delim) bool {
+	switch {
+	case opener.c != closer.c || !opener.canOpen:
+		return false
+	case opener.c == '~':
+		return opener.n == 2 && closer.n == 2
+	case (opener.canClose || closer.canOpen) &&
+		(opener.orig+closer.orig)%3 == 0 &&
+		(opener.orig%3 != 0 || closer.orig%3 != 0):
+		return false // the rule of 3
+	}
+	return true
+}
+
+// remove removes the run from the delimiter stack.
+func (d *delim) remove() {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next != nil {
+		d.next.prev = d.prev
+	}
+}
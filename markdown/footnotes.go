@@ -0,0 +1,287 @@
+//
+// footnotes.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// definitions and functions for footnotes.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/FrankStorbeck/md2html/branch"
+)
+
+// Note holds a footnote definition.
+type Note struct {
+	Lines []string // lines of the definition, without the label and the indent
+	N     int      // number of the footnote, 0 while it isn't referenced
+	Refs  int      // number of references to the footnote
+}
+
+// Notes holds footnote definitions by their normalised labels and the labels
+// of the referenced footnotes in order of their first reference.
+type Notes struct {
+	defs  map[string]*Note
+	order []string
+	refs  []string // labels of the references so far
+}
+
+// CollectNotes collects the footnote definitions in 'lines'. It returns them
+// and the lines without the definitions. The lines of a definition following
+// the first one are indented by 4 spaces, or continue its first paragraph.
+// When a label is defined more than once, the first definition is used.
+func CollectNotes(lines []string) (*Notes, []string) {
+	notes := &Notes{defs: map[string]*Note{}}
+	r := make([]string, 0, len(lines))
+	fence := ""
+	var note *Note
+	blanks := 0 // empty lines following the definition so far
+	isPara := false
+
+	for _, line := range lines {
+		indnt := CountLeading(line, ' ', -1)
+		t := strings.TrimSpace(line)
+
+		if note != nil {
+			switch {
+			case len(t) <= 0:
+				blanks++
+				continue
+			case indnt >= 4:
+				for ; blanks > 0; blanks-- {
+					note.Lines = append(note.Lines, "\n")
+				}
+				note.Lines = append(note.Lines, StripIndent(line, 4))
+				continue
+			case blanks <= 0 && !StartsBlock(t) && !strings.HasPrefix(t, "[^"):
+				// lazy continuation line
+				note.Lines = append(note.Lines, line)
+				continue
+			}
+			note = nil
+			isPara = false
+			for ; blanks > 0; blanks-- {
+				r = append(r, "\n")
+			}
+		}
+
+		switch {
+		case len(fence) > 0:
+			if IsClosingFence(line, fence) {
+				fence = ""
+			}
+
+		case indnt < 4 && IsFence(t):
+			fence, _ = SplitFence(t)
+
+		case indnt < 4 && !isPara:
+			if label, txt, ok := ParseNoteDef(t); ok {
+				note = &Note{Lines: []string{txt + "\n"}}
+				if _, found := notes.defs[label]; !found {
+					notes.defs[label] = note
+				}
+				blanks = 0
+				continue
+			}
+		}
+
+		isPara = len(t) > 0 && len(fence) <= 0 && !IsFence(t) &&
+			CountLeading(t, '#', 6) <= 0 && !IsThematicBreak(t) &&
+			(isPara || indnt < 4)
+		r = append(r, line)
+	}
+
+	return notes, r
+}
+
+// ParseNoteDef parses the footnote definition '[^label]: text' in 's'. It
+// returns the normalised label and the text. When 's' isn't a definition, 'ok'
+// is false.
+func ParseNoteDef(s string) (label, txt string, ok bool) {
+	j := strings.Index(s, "]:")
+	if len(s) < 5 || !strings.HasPrefix(s, "[^") || j < 0 {
+		return "", "", false
+	}
+	label = NormalizeLabel(Unescape(s[2:j]))
+	if len(label) <= 0 || strings.ContainsAny(label, " []") {
+		return "", "", false
+	}
+	return label, strings.TrimSpace(s[j+2:]), true
+}
+
+// Ref returns the number of the footnote with the (escaped) label 'label' and
+// the number of references to it so far, including this one. A footnote gets
+// its number when it is referenced for the first time. 'ok' is false when it
+// isn't defined.
+func (notes *Notes) Ref(label string) (n, ref int, ok bool) {
+	if notes == nil {
+		return 0, 0, false
+	}
+	label = NormalizeLabel(html.UnescapeString(label))
+	note, ok := notes.defs[label]
+	if !ok {
+		return 0, 0, false
+	}
+	notes.count(label, note)
+	return note.N, note.Refs, true
+}
+
+// count counts a reference to 'note', which has the label 'label'.
+func (notes *Notes) count(label string, note *Note) {
+	if note.N <= 0 {
+		notes.order = append(notes.order, label)
+		note.N = len(notes.order)
+	}
+	note.Refs++
+	notes.refs = append(notes.refs, label)
+}
+
+// Mark returns the number of references counted so far.
+func (notes *Notes) Mark() int {
+	if notes == nil {
+		return 0
+	}
+	return len(notes.refs)
+}
+
+// Undo forgets the references counted after 'mark', and the numbers they
+// gave to their footnotes. It returns their labels, so Redo can count them
+// again. It is used when a translated line is dropped or translated anew.
+func (notes *Notes) Undo(mark int) []string {
+	if notes == nil || mark >= len(notes.refs) {
+		return nil
+	}
+	labels := append([]string{}, notes.refs[mark:]...)
+	for i := len(labels) - 1; i >= 0; i-- {
+		note := notes.defs[labels[i]]
+		note.Refs--
+		if note.Refs <= 0 {
+			// its first reference, so the last footnote numbered
+			note.N = 0
+			notes.order = notes.order[:len(notes.order)-1]
+		}
+	}
+	notes.refs = notes.refs[:mark]
+	return labels
+}
+
+// Redo counts the references with the labels 'labels' that Undo returned.
+func (notes *Notes) Redo(labels []string) {
+	for _, label := range labels {
+		notes.count(label, notes.defs[label])
+	}
+}
+
+// Footnotes translates the references to footnotes like [^label] to their
+// html equivalents. References to undefined footnotes are left as they are.
+func (in *Inliner) Footnotes(s string) string {
+	for i := strings.Index(s, "[^"); i >= 0; {
+		j := strings.Index(s[i:], "]")
+		if j <= 2 {
+			break
+		}
+		if n, ref, ok := in.Notes.Ref(in.unstash(s[i+2 : i+j])); ok {
+			return s[:i] + in.keep(fmt.Sprintf("<sup class=\"footnote-ref\">"+
+				"<a href=\"#fn-%d\" id=\"%s\">%d</a></sup>", n, noteRefID(n, ref), n)) +
+				in.Footnotes(s[i+j+1:])
+		}
+		k := strings.Index(s[i+2:], "[^")
+		if k < 0 {
+			break
+		}
+		i += k + 2
+	}
+	return s
+}
+
+// noteRefID returns the id of the 'ref'-th reference to footnote 'n'.
+func noteRefID(n, ref int) string {
+	if ref <= 1 {
+		return fmt.Sprintf("fnref-%d", n)
+	}
+	return fmt.Sprintf("fnref-%d-%d", n, ref)
+}
+
+// Footnotes adds a section holding the referenced footnotes to the tree, in
+// the order of their numbers. Each footnote ends with links back to its
+// references.
+func (ht *HTMLTree) Footnotes() {
+	notes := ht.in.Notes
+	if notes == nil || len(notes.order) <= 0 {
+		return
+	}
+	ht.closeParagraph()
+
+	sect, _ := ht.root.AddBranch(-1, cSection)
+	sect.Info = "class=\"footnotes\""
+	ol, _ := sect.AddBranch(-1, cOl)
+
+	// footnotes can refer to footnotes that aren't numbered yet
+	for i := 0; i < len(notes.order); i++ {
+		note := notes.defs[notes.order[i]]
+		li, _ := ol.AddBranch(-1, cLi)
+		li.Info = fmt.Sprintf("id=\"fn-%d\"", note.N)
+		c := ht.newChild(li)
+		for _, line := range note.Lines {
+			c.Build(line)
+		}
+		c.Finish()
+		TidyItem(li, false)
+		BackLinks(li, note)
+	}
+	ht.br = ht.root
+}
+
+// BackLinks adds the links back to the references to 'note' to its list item
+// 'li'. They end its last paragraph.
+func BackLinks(li *branch.Branch, note *Note) {
+	links := make([]string, 0, note.Refs)
+	for ref := 1; ref <= note.Refs; ref++ {
+		s := "&#8617;"
+		if ref > 1 {
+			s += fmt.Sprintf("<sup>%d</sup>", ref)
+		}
+		links = append(links, fmt.Sprintf("<a href=\"#%s\" class=\"footnote-backref\">%s</a>",
+			noteRefID(note.N, ref), s))
+	}
+	s := strings.Join(links, " ")
+
+	last, _ := li.SiblingN(-1)
+	p, ok := last.(*branch.Branch)
+	if !ok || p.ID != cP {
+		p, _ = li.AddBranch(-1, cP)
+	} else if txt, isText := p.Siblings()[p.Len()-1].(string); isText {
+		p.Remove(-1)
+		s = txt + " " + s
+	}
+	p.Add(-1, s)
+}
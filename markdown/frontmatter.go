@@ -0,0 +1,340 @@
+//
+// frontmatter.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// definitions and functions for YAML and TOML front matter.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"strings"
+)
+
+// FrontMatter holds the keys in the front matter of a document, in lower
+// case, with their values. A list holds more than one value.
+type FrontMatter map[string][]string
+
+// ParseFrontMatter parses the YAML front matter between two "---" lines, or
+// the TOML front matter between two "+++" lines, at the start of 'lines'. It
+// returns the front matter and the lines following it. Only simple keys with
+// strings or lists of strings as values are supported; nested keys are
+// joined by a '.'. Front matter must hold at least one key and no other kinds
+// of lines. When there is none, it returns nil and 'lines'.
+func ParseFrontMatter(lines []string) (FrontMatter, []string) {
+	if len(lines) <= 0 {
+		return nil, lines
+	}
+	fence := strings.TrimRight(lines[0], " \t\r\n")
+	if fence != "---" && fence != "+++" {
+		return nil, lines
+	}
+
+	for n, line := range lines[1:] {
+		t := strings.TrimRight(line, " \t\r\n")
+		if t != fence && (fence != "---" || t != "...") {
+			continue
+		}
+		parse := parseTOML
+		if fence == "---" {
+			parse = parseYAML
+		}
+		if fm, ok := parse(lines[1 : n+1]); ok {
+			return fm, lines[n+2:]
+		}
+		return nil, lines
+	}
+	return nil, lines
+}
+
+// Value returns the value of 'key', or its values separated by ", " for a
+// list. It returns "" when there is no such key.
+func (fm FrontMatter) Value(key string) string {
+	return strings.Join(fm[key], ", ")
+}
+
+// Options returns 'opts' completed with the title, description, author, lang,
+// keywords and stylesheets in the front matter. Settings in 'opts' take
+// precedence; style sheets are added to the ones in 'opts'. White space around
+// the values for the HTML head, like the line end of a block scalar, is
+// dropped.
+func (fm FrontMatter) Options(opts Options) Options {
+	for key, vals := range fm {
+		switch key {
+		case "title":
+			if len(opts.Title) <= 0 {
+				opts.Title = strings.TrimSpace(fm.Value(key))
+			}
+		case "description":
+			if len(opts.Description) <= 0 {
+				opts.Description = strings.TrimSpace(fm.Value(key))
+			}
+		case "author":
+			if len(opts.Author) <= 0 {
+				opts.Author = strings.TrimSpace(fm.Value(key))
+			}
+		case "lang":
+			if len(opts.Lang) <= 0 {
+				opts.Lang = strings.TrimSpace(fm.Value(key))
+			}
+		case "keywords":
+			if len(opts.Keywords) <= 0 {
+				opts.Keywords = vals
+			}
+		case "stylesheets":
+			// don't change the slice of the caller
+			sheets := make([]string, 0, len(opts.Stylesheets)+len(vals))
+			sheets = append(sheets, opts.Stylesheets...)
+			opts.Stylesheets = append(sheets, vals...)
+		}
+	}
+	return opts
+}
+
+// parseYAML parses the YAML 'lines': keys followed by a ':' and a value, which
+// can be a list between '[' and ']', or a '|' or '>' block scalar followed by
+// its indented lines. A key without a value can be followed by the items of a
+// list, starting with a '-', or by indented keys. When a line is none of these
+// or there are no keys, 'ok' is false.
+func parseYAML(lines []string) (fm FrontMatter, ok bool) {
+	fm = FrontMatter{}
+	parent := ""
+	list := ""
+	for k := 0; k < len(lines); k++ {
+		line := lines[k]
+		indnt := CountLeading(line, ' ', -1)
+		t := strings.TrimSpace(line)
+		if len(t) <= 0 || t[0] == '#' {
+			continue
+		}
+
+		if strings.HasPrefix(t, "- ") || t == "-" {
+			if len(list) <= 0 {
+				return nil, false
+			}
+			fm[list] = append(fm[list], yamlValue(t[1:]))
+			continue
+		}
+
+		i := strings.Index(t, ":")
+		if i <= 0 {
+			return nil, false
+		}
+		key := strings.ToLower(strings.TrimSpace(t[:i]))
+		if indnt > 0 && len(parent) > 0 {
+			key = parent + "." + key
+		} else {
+			parent = ""
+		}
+		v := strings.TrimSpace(t[i+1:])
+		list = ""
+		switch {
+		case len(v) <= 0:
+			if indnt <= 0 {
+				parent = key
+			}
+			list = key
+		case v[0] == '[' && v[len(v)-1] == ']':
+			fm[key] = splitList(v[1:len(v)-1], yamlValue)
+		case isBlockScalar(yamlValue(v)):
+			txt, n := yamlBlock(lines[k+1:], indnt, yamlValue(v))
+			fm[key] = []string{txt}
+			k += n
+		default:
+			fm[key] = []string{yamlValue(v)}
+		}
+	}
+	return fm, len(fm) > 0
+}
+
+// isBlockScalar tests if 's' is the header of a YAML block scalar: a '|' or
+// '>' followed by an optional indentation and chomping indicator.
+func isBlockScalar(s string) bool {
+	if len(s) <= 0 || s[0] != '|' && s[0] != '>' || len(s) > 3 {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if strings.IndexByte("+-123456789", s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlBlock returns the value of the block scalar with the header 'hdr' for a
+// key with indent 'indnt', and the number of its lines at the start of
+// 'lines'. These are indented more than the key. A literal '|' scalar keeps
+// its line ends; a folded '>' one joins its lines by a space, unless they are
+// empty or more indented. Only the '+' chomping indicator keeps the empty lines
+// at its end, and the '-' one drops its final line end.
+func yamlBlock(lines []string, indnt int, hdr string) (string, int) {
+	blockIndnt := 0 // indent of the contents
+	if i := strings.IndexAny(hdr, "123456789"); i > 0 {
+		blockIndnt = indnt + int(hdr[i]-'0')
+	}
+
+	n := 0
+	txt := []string{}
+	for ; n < len(lines); n++ {
+		line := strings.TrimRight(lines[n], "\r\n")
+		if len(strings.TrimSpace(line)) <= 0 {
+			txt = append(txt, "")
+			continue
+		}
+		i := CountLeading(line, ' ', -1)
+		if blockIndnt <= 0 && i > indnt {
+			blockIndnt = i
+		}
+		if blockIndnt <= 0 || i < blockIndnt {
+			break
+		}
+		txt = append(txt, line[blockIndnt:])
+	}
+
+	end := len(txt)
+	for end > 0 && len(txt[end-1]) <= 0 {
+		end--
+	}
+	folds := func(t string) bool { return len(t) > 0 && t[0] != ' ' && t[0] != '\t' }
+	var b strings.Builder
+	for i, t := range txt[:end] {
+		switch {
+		case i <= 0:
+		case hdr[0] == '>' && folds(txt[i-1]) && folds(t):
+			b.WriteString(" ")
+		case hdr[0] == '>' && folds(txt[i-1]) && len(t) <= 0:
+			// the line end before an empty line is dropped
+		default:
+			b.WriteString("\n")
+		}
+		b.WriteString(t)
+	}
+
+	switch {
+	case strings.Contains(hdr, "-") || end <= 0:
+	case strings.Contains(hdr, "+"):
+		b.WriteString(strings.Repeat("\n", len(txt)-end+1))
+	default:
+		b.WriteString("\n")
+	}
+	return b.String(), n
+}
+
+// yamlValue returns the YAML value 's' without its quotes, or without a
+// trailing comment when it isn't quoted.
+func yamlValue(s string) string {
+	s = strings.TrimSpace(s)
+	if l := len(s); l >= 2 && (s[0] == '"' || s[0] == '\'') && s[l-1] == s[0] {
+		return unquote(s)
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+// parseTOML parses the TOML 'lines': keys followed by a '=' and a value, which
+// can be an array between '[' and ']'. Keys following a table header like
+// '[table]' are prefixed by the name of the table and a '.'. When a line is
+// none of these or there are no keys, 'ok' is false.
+func parseTOML(lines []string) (fm FrontMatter, ok bool) {
+	fm = FrontMatter{}
+	table := ""
+	for _, line := range lines {
+		t := strings.TrimSpace(line)
+		if len(t) <= 0 || t[0] == '#' {
+			continue
+		}
+		if t[0] == '[' && t[len(t)-1] == ']' {
+			table = strings.ToLower(strings.Trim(t, "[] ")) + "."
+			continue
+		}
+
+		i := strings.Index(t, "=")
+		if i <= 0 {
+			return nil, false
+		}
+		key := table + strings.ToLower(strings.Trim(strings.TrimSpace(t[:i]), "\"'"))
+		v := strings.TrimSpace(t[i+1:])
+		if j := strings.LastIndex(v, "]"); len(v) > 0 && v[0] == '[' && j > 0 {
+			fm[key] = splitList(v[1:j], tomlValue)
+			continue
+		}
+		fm[key] = []string{tomlValue(v)}
+	}
+	return fm, len(fm) > 0
+}
+
+// tomlValue returns the TOML value 's' without its quotes, or without a
+// trailing comment when it isn't quoted.
+func tomlValue(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		if j := strings.LastIndexByte(s, s[0]); j > 0 {
+			return unquote(s[:j+1])
+		}
+	}
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+// splitList splits the comma separated list 's' and returns its values, each
+// translated by 'value'. Commas between quotes don't separate values.
+func splitList(s string, value func(string) string) []string {
+	vals := []string{}
+	quote := byte(0)
+	n := 0
+	for i := 0; i <= len(s); i++ {
+		switch {
+		case i == len(s) || quote == 0 && s[i] == ',':
+			if v := value(s[n:i]); len(v) > 0 {
+				vals = append(vals, v)
+			}
+			n = i + 1
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote == 0 && (s[i] == '"' || s[i] == '\''):
+			quote = s[i]
+		}
+	}
+	return vals
+}
+
+// unquote returns the string 's' without its enclosing quotes. In a string
+// between '"' runes, escape sequences like \" and \n are translated.
+func unquote(s string) string {
+	q := s[0]
+	s = s[1 : len(s)-1]
+	if q != '"' || !strings.Contains(s, "\\") {
+		return s
+	}
+	return strings.NewReplacer("\\\"", "\"", "\\\\", "\\", "\\n", "\n",
+		"\\t", "\t").Replace(s)
+}
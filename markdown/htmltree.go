@@ -0,0 +1,1159 @@
+//
+// htmltree.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// definitions and functions for constructing an HTML tree.
+//
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/FrankStorbeck/md2html/branch"
+	"github.com/FrankStorbeck/md2html/highlight"
+	"github.com/FrankStorbeck/md2html/mathml"
+)
+
+// TableInfo holds table data.
+type TableInfo []int
+
+// HTMLTree is a struct for holding the data for the construction of a HTML
+// tree.
+type HTMLTree struct {
+	admFence    string          // fence that closes the admonition the tree builds
+	br          *branch.Branch  // current branch
+	brk         string          // hard line break marker ending the current line
+	child       *HTMLTree       // tree for the open block quote, admonition, list item or definition
+	childIndnt  int             // indent of the contents of the open list item or definition
+	ddLoose     bool            // true when the open definition is loose
+	fence       string          // fence that started the fenced code
+	fenceIndnt  int             // indent of the fence that started the code
+	gap         bool            // true when an empty line separates two blocks
+	hardBr      bool            // true when the last text line ended with a hard break
+	htmlCond    int             // start condition of the open HTML block, or 0
+	in          Inliner         // translator for inline definitions
+	isHighLited bool            // true when text is high ligted
+	isQuoted    bool            // true is the lines are precoded quotes
+	lexer       highlight.Lexer // lexer for highlighting fenced code
+	list        *branch.Branch  // open list
+	listLoose   bool            // true when the open list is loose
+	mark        int             // footnote references counted before the current line
+	marker      byte            // bullet or delimiter of the open list
+	math        []string        // lines of the open display math block, nil if there is none
+	opts        Options         // conversion settings
+	prev        string          // previous line, before inline translation
+	quoteEmpty  int             // empty lines following the precoded quote so far
+	sCount      int             // string number
+	root        *branch.Branch  // root branch
+	tblInfo     TableInfo       // table information
+
+}
+
+const (
+	noAlign = iota
+	left
+	right
+	center
+)
+
+// AddText adds the text line 's' to the current branch. When the text line
+// before it ended with a hard line break, a '<br/>' is added to that line.
+func (ht *HTMLTree) AddText(s string) {
+	if ht.hardBr {
+		if prev, err := ht.br.SiblingN(-1); err == nil {
+			if ps, ok := prev.(string); ok {
+				ht.br.Remove(-1)
+				ht.br.Add(-1, ps+"<br/>\n")
+			}
+		}
+	}
+
+	ht.hardBr = len(ht.brk) > 0
+	ht.br.Add(-1, strings.TrimSuffix(s, ht.brk))
+}
+
+// BlockQuote starts a block quote with the line 's', which has a '>' at
+// position 'indnt'. Its contents are built by a tree of their own.
+func (ht *HTMLTree) BlockQuote(s string, indnt int) error {
+	ht.closeParagraph()
+	ht.isQuoted = false
+	ht.quoteEmpty = 0
+	ht.tblInfo = TableInfo{}
+
+	ht.br, _ = ht.root.AddBranch(-1, cBlockQuote)
+	ht.child = ht.newChild(ht.br)
+	t := QuoteText(s, indnt)
+	if typ := AlertType(t); len(typ) > 0 {
+		// an alert becomes an admonition when the block quote ends
+		ht.br.Info = fmt.Sprintf("class=\"admonition %s\"", typ)
+		ht.br.Add(0, NewAdmonitionTitle(strings.ToUpper(typ[:1])+typ[1:]))
+		return nil
+	}
+	return ht.child.Build(t)
+}
+
+// Admonition starts an admonition with the line 's', which starts with a
+// fence of ':' runes followed by its type and title. Its contents are built by
+// a tree of their own until a line closes the fence.
+func (ht *HTMLTree) Admonition(s string) {
+	ht.closeParagraph()
+	ht.isQuoted = false
+	ht.quoteEmpty = 0
+	ht.tblInfo = TableInfo{}
+
+	fence, typ, title := SplitAdmonition(s)
+	ht.br, _ = ht.root.AddBranch(-1, cDiv)
+	ht.br.Info = fmt.Sprintf("class=\"admonition %s\"", typ)
+	ht.br.Add(-1, NewAdmonitionTitle(ht.in.Inline(title)))
+	ht.child = ht.newChild(ht.br)
+	ht.child.admFence = fence
+}
+
+// NewAdmonitionTitle returns a branch holding the title 's' of an admonition.
+func NewAdmonitionTitle(s string) *branch.Branch {
+	p := branch.NewBranch(cP)
+	p.Info = "class=\"admonition-title\""
+	p.Add(-1, s)
+	return p
+}
+
+// ClosesAdmonition tests if 'line' closes the fenced admonition the tree
+// builds. It doesn't when fenced code, a raw HTML block or another fenced
+// admonition is open in the tree.
+func (ht *HTMLTree) ClosesAdmonition(line string) bool {
+	if !IsClosingFence(line, ht.admFence) {
+		return false
+	}
+	for d := ht; d != nil; d = d.child {
+		if d.isHighLited || d.htmlCond > 0 || d != ht && len(d.admFence) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// QuoteText returns the line 's' without the '>' at position 'indnt' and the
+// space following it.
+func QuoteText(s string, indnt int) string {
+	s = s[indnt+1:]
+	if len(s) > 0 && s[0] == ' ' {
+		return s[1:]
+	}
+	return s
+}
+
+// Continue passes the line 's' to the open block quote, list item or
+// definition when the line continues it, and returns true. 'line' holds the
+// trimmed line and 'indnt' its indent. Otherwise the block quote, list item or
+// definition ends and false is returned.
+func (ht *HTMLTree) Continue(s, line string, indnt int) (bool, error) {
+	c := ht.child
+	empty := len(strings.TrimSpace(line)) <= 0
+	switch {
+	case len(c.admFence) > 0 && c.ClosesAdmonition(line):
+		ht.EndChild()
+		return true, nil
+
+	case len(c.admFence) > 0:
+		return true, c.Build(s)
+
+	case c.root.ID == cBlockQuote && !empty && indnt < 4 && line[indnt] == '>':
+		return true, c.Build(QuoteText(s, indnt))
+
+	case c.root.ID != cBlockQuote && empty:
+		return true, c.Build(strings.TrimLeft(s, " "))
+
+	case c.root.ID != cBlockQuote && indnt >= ht.childIndnt:
+		return true, c.Build(s[ht.childIndnt:])
+
+	case c.root.ID == cDd && indnt < 4 && IsDefMarker(line[indnt:]):
+		// the next definition
+
+	case !empty && c.IsLazy(line, indnt):
+		return true, c.Build(s)
+	}
+
+	ht.EndChild()
+	return false, nil
+}
+
+// IsLazy tests if 'line', with indent 'indnt', is a lazy continuation line:
+// a line that continues the paragraph in the innermost open block of the tree,
+// without the '>' or indent it needs, and that doesn't start a block itself
+// or underline a header.
+func (ht *HTMLTree) IsLazy(line string, indnt int) bool {
+	d := ht
+	for d.child != nil {
+		d = d.child
+	}
+	if !d.inParagraph() {
+		return false
+	}
+	if indnt >= 4 {
+		return true
+	}
+
+	t := line[indnt:]
+	return !StartsBlock(t) && !OnlyRunes(t, '=') && !OnlyRunes(t, '-')
+}
+
+// StartsBlock tests if the non empty line 't', without its indent, starts a
+// block that can interrupt a paragraph.
+func StartsBlock(t string) bool {
+	return t[0] == '>' || IsThematicBreak(t) || IsBullet(t) ||
+		OrderedListMarker(t) > 0 || CountLeading(t, '#', 6) > 0 ||
+		IsFence(t) || IsAdmonition(t) || IsMathBlock(t) || HTMLBlockStart(t, true) > 0
+}
+
+// MathBlock adds the line 's' to a block of display math, which starts with
+// "$$" and ends with a line ending with "$$". Then the TeX math is added as
+// MathML.
+func (ht *HTMLTree) MathBlock(s string) {
+	t := strings.TrimSpace(s)
+	if ht.math == nil {
+		ht.closeParagraph()
+		ht.Reset()
+		ht.math = []string{}
+		t = t[2:]
+	} else if strings.HasSuffix(t, "$$") {
+		ht.math = append(ht.math, strings.TrimSuffix(t, "$$"))
+		ht.closeParagraph()
+		b, _ := ht.root.AddBranch(-1, cRawHTML)
+		b.Add(-1, mathml.Convert(strings.Join(ht.math, "\n"), true)+"\n")
+		ht.math = nil
+		ht.br, _ = ht.root.AddBranch(-1, cP)
+		return
+	}
+	ht.math = append(ht.math, t)
+}
+
+// newChild returns a tree for building the contents of the block quote, list
+// item or definition 'br'.
+func (ht *HTMLTree) newChild(br *branch.Branch) *HTMLTree {
+	c := &HTMLTree{in: ht.in, opts: ht.opts, root: br}
+	c.br, _ = br.AddBranch(-1, cP)
+	return c
+}
+
+// EndChild ends the open block quote, list item or definition. A list item
+// holding blocks separated by an empty line makes its list loose; a definition
+// holding them is loose itself.
+func (ht *HTMLTree) EndChild() {
+	c := ht.child
+	ht.child = nil
+	c.Finish()
+	c.closeParagraph()
+	switch c.root.ID {
+	case cBlockQuote:
+		if len(c.root.Info) > 0 {
+			// an alert
+			c.root.ID = cDiv
+		}
+	case cDd:
+		TidyItem(c.root, !ht.ddLoose && !c.gap)
+	case cLi:
+		if c.gap {
+			ht.listLoose = true
+		}
+		return
+	}
+	ht.br, _ = ht.root.AddBranch(-1, cP)
+}
+
+// Traverse traverses a slice of siblings. For each string found the function
+// 'f' is called with this string as an argument. This sibling is then replaced
+// by the result of 'f'. When the sibling is a pointer to a branch Traverse is
+// called with a slice of all siblings in this branch as the argument.
+func Traverse(intf []interface{}, f func(string) []interface{}) []interface{} {
+	r := []interface{}{}
+	for _, in := range intf {
+		switch in.(type) {
+		case string:
+			r = append(r, f(in.(string))...)
+		case *branch.Branch:
+			br := in.(*branch.Branch)
+			sblgs := br.Siblings()
+			br.RemoveAll()
+			br.Add(-1, Traverse(sblgs, f)...)
+		}
+	}
+	return r
+}
+
+// Plain removes all tags and comments from 's', changes its spaces to '-' and
+// finally puts everything in lower case.
+func Plain(s string) string {
+	return strings.ToLower(strings.Replace(StripTags(s), " ", "-", -1))
+}
+
+// Build reconstructs the HTML tree based on the contents of 's'.
+func (ht *HTMLTree) Build(s string) error {
+	raw := s
+	var err error
+	ht.sCount++
+	indnt := CountLeading(s, ' ', -1)
+	line := strings.Repeat(" ", indnt) + strings.TrimSpace(s)
+	if len(strings.TrimSpace(s)) <= 0 {
+		// a line with only white space is empty
+		indnt = 0
+		line = ""
+	}
+	prev, prevMark := ht.prev, ht.mark
+	ht.prev, ht.mark = line, ht.in.Notes.Mark()
+	afterEmpty := ht.sCount > 1 && len(strings.TrimSpace(prev)) <= 0
+
+	if ht.child != nil {
+		if ok, err := ht.Continue(raw, line, indnt); ok || err != nil {
+			return err
+		}
+	}
+
+	ht.brk = HardBreak(raw)
+	if ht.htmlCond > 0 {
+		return ht.HTMLBlock(raw, 0)
+	}
+	if ht.math != nil {
+		ht.MathBlock(raw)
+		return nil
+	}
+
+	nEnd := 0 // end of number for ordered list
+	item := false
+	if indnt < 4 && !ht.isHighLited && !IsThematicBreak(line) {
+		nEnd = OrderedListMarker(line[indnt:])
+		item = nEnd > 0 || IsBullet(line[indnt:])
+		if item && ht.inParagraph() {
+			// an empty item, or a numbered one not starting with 1, can't
+			// interrupt a paragraph
+			start, _ := strconv.Atoi(line[indnt : indnt+nEnd])
+			if len(strings.TrimSpace(line[indnt+nEnd+1:])) <= 0 || nEnd > 0 && start != 1 {
+				item = false
+				nEnd = 0
+			}
+		}
+	}
+	if !item && indnt < 4 && !ht.isHighLited && !ht.isQuoted && IsDefMarker(line[indnt:]) {
+		if dl := ht.DefList(afterEmpty); dl != nil {
+			return ht.Definition(dl, raw, indnt, afterEmpty)
+		}
+	}
+	if ht.list != nil && !item {
+		ht.EndList()
+	}
+	if afterEmpty && len(strings.TrimSpace(line)) > 0 && !ht.isHighLited &&
+		!(ht.isQuoted && indnt >= 4) && !(item && ht.list != nil) {
+		// a new block after an empty line
+		ht.gap = true
+	}
+
+	if !ht.isHighLited && indnt < 4 && IsMathBlock(line[indnt:]) {
+		ht.MathBlock(raw)
+		return nil
+	}
+	if !ht.isHighLited && !ht.in.NoHTML && indnt < 4 {
+		if cond := HTMLBlockStart(line[indnt:], ht.inParagraph()); cond > 0 {
+			return ht.HTMLBlock(raw, cond)
+		}
+	}
+
+	s = ht.in.Inline(line)
+	leadingHash := CountLeading(s, '#', 6)
+	// footnote references only count when 's' is kept
+	refs := ht.in.Notes.Undo(ht.mark)
+
+	if l := len(s); l > 0 {
+		newTblInfo := TableInfo{}
+		if len(ht.tblInfo) <= 0 {
+			// can be a new table
+			newTblInfo = CountTblColls(s)
+		}
+
+		switch {
+		case ht.isHighLited && IsClosingFence(line, ht.fence):
+			// fenced code ends
+			return ht.HighLite(line, indnt)
+
+		case ht.isHighLited:
+			// Pre coded text
+			ln := StripIndent(raw, ht.fenceIndnt)
+			if ht.lexer == nil {
+				ln = html.EscapeString(ln)
+			}
+			ht.br.Add(-1, ln)
+
+		case indnt < 4 && IsFence(line[indnt:]):
+			// fenced code starts
+			return ht.HighLite(line, indnt)
+
+		case ht.inParagraph() && (OnlyRunes(s, '=') || OnlyRunes(s, '-')):
+			// previous line was a <h1> or <h2> line
+			ht.ChangePrevToHdr(s)
+			ht.in.Notes.Redo(refs)
+
+		case IsThematicBreak(line) && indnt < 4:
+			// horizontal rule
+			ht.Rule()
+
+		case leadingHash > 0:
+			// <h'leadingHash'> line
+			ht.in.Notes.Redo(refs)
+			ht.Header(s[leadingHash:], leadingHash)
+
+		case l > 4 && indnt >= 4 && (ht.isQuoted || ht.br.ID == cP && !ht.inParagraph()):
+			// pre coded quote
+			err = ht.Quote(raw)
+
+		case indnt < 4 && line[indnt] == '>':
+			// block quote
+			err = ht.BlockQuote(raw, indnt)
+
+		case indnt < 4 && IsAdmonition(line[indnt:]):
+			// fenced admonition
+			ht.Admonition(line[indnt:])
+
+		case item:
+			// new list item
+			err = ht.ListItem(raw, indnt, nEnd, afterEmpty)
+
+		case len(ht.tblInfo) > 0:
+			// table row?
+			err = ht.TblRow(line, s, refs)
+
+		case len(newTblInfo) > 0:
+			// previous line was a table header row
+			err = ht.ChangePrevToTblHdr(prev, prevMark, &newTblInfo)
+
+		default:
+			if ht.isQuoted {
+				err = ht.TryParent(2)
+				if err != nil {
+					return err
+				}
+				ht.isQuoted = false
+				ht.quoteEmpty = 0
+				ht.br, _ = ht.br.AddBranch(-1, "p")
+			}
+
+			ht.in.Notes.Redo(refs)
+			ht.AddText(s[indnt:])
+		}
+	} else {
+		// empty line
+		ht.hardBr = false
+		switch {
+		case len(ht.tblInfo) > 0:
+			// end of table
+			err = ht.TryParent(1)
+			ht.tblInfo = TableInfo{}
+
+		case ht.isHighLited:
+			ht.br.Add(-1, StripIndent(raw, ht.fenceIndnt))
+
+		case ht.isQuoted:
+			// only part of the precoded quote when more lines follow
+			ht.quoteEmpty++
+
+		default:
+			ht.TryParent(1)
+			ht.br, _ = ht.br.AddBranch(-1, "p")
+		}
+	}
+	return err
+}
+
+// ChangePrevToHdr changes the string that was added just before into a header
+// line. If 's' contains '-' runes, it will be a level 2, otherwise a leve 1.
+func (ht *HTMLTree) ChangePrevToHdr(s string) {
+	prev, err := ht.br.Remove(-1)
+	if err != nil {
+		// Remove failed, add the string to a new <p>
+		ht.br, _ = ht.root.AddBranch(-1, "p")
+		ht.br.Add(-1, s)
+	} else {
+		ps, ok := prev.(string)
+		if ok { // 'prev' must be a string
+			lvl := 1
+			if s[0] == '-' {
+				lvl = 2
+			}
+			ht.Header(ps, lvl)
+		} else {
+			ht.br.Add(-1, prev) // restore 'prev'
+		}
+	}
+}
+
+// ChangePrevToTblHdr changes the string that was added just before into a table
+// header. 'prev' holds the line for it, before its inline translation, and
+// 'mark' the footnote references counted before it.
+func (ht *HTMLTree) ChangePrevToTblHdr(prev string, mark int, newTblInfo *TableInfo) error {
+	ht.tblInfo = *newTblInfo
+	ln, err := ht.br.Remove(-1)
+	if err != nil {
+		return err
+	}
+
+	s, ok := ln.(string)
+	if ok { // 'ln' must be a string
+		// ht.br, _ = ht.br.Parent(1)
+		ht.br, _ = ht.br.AddBranch(-1, "table")
+		ht.br.Info = "style=\"width: 100%\""
+		// the cells count the footnote references of 'prev' again
+		refs := ht.in.Notes.Undo(mark)
+		b := TRow(prev, true, &(ht.tblInfo), &ht.in)
+		if b != nil {
+			ht.br.Add(-1, b)
+		} else {
+			ht.in.Notes.Redo(refs)
+			err = ht.TryParent(1)
+			if err != nil {
+				return err
+			}
+			ht.br.Add(-1, s)
+			ht.tblInfo = TableInfo{}
+		}
+	} else {
+		// restore 'ln'
+		ht.br.Add(-1, ln)
+	}
+	return nil
+}
+
+// CountTblColls test is a table separator line is found, and if true it
+// analyses the table structure
+func CountTblColls(s string) TableInfo {
+	cols := strings.Split(strings.TrimSpace(s), "|")
+
+	l := len(cols)
+	if l < 3 || len(cols[0]) > 0 || len(cols[l-1]) > 0 {
+		return TableInfo{}
+	}
+
+	tblInfo := make(TableInfo, len(cols)-2)
+	cols = cols[1 : l-1] // trim first and last elements
+
+	for i, c := range cols {
+		c = strings.TrimSpace(c)
+		l := len(c)
+		if l > 0 {
+
+			if c1 := strings.Index(c, ":"); c1 == 0 {
+				if c2 := strings.Index(c[1:], ":"); c2 == l-2 {
+					if !OnlyRunes(c[1:l-1], '-') {
+						return TableInfo{}
+					}
+					tblInfo[i] = center
+				} else {
+					if !OnlyRunes(c[1:], '-') {
+						return TableInfo{}
+					}
+					tblInfo[i] = left
+				}
+			} else if c1 == l-1 {
+				if !OnlyRunes(c[:l-1], '-') {
+					return TableInfo{}
+				}
+				tblInfo[i] = right
+			} else {
+				if !OnlyRunes(c, '-') {
+					return TableInfo{}
+				}
+				// tblInfo[i] = noAlign
+			}
+		}
+	}
+	return tblInfo
+}
+
+// Header adds a header with level 'n' to the HTML tree.
+func (ht *HTMLTree) Header(s string, n int) {
+	ht.closeParagraph()
+	ht.br.Add(-1, NewHeader(s, n))
+	ht.Reset()
+}
+
+// inParagraph tests if the current branch is a paragraph to which a line of
+// text was added just before.
+func (ht *HTMLTree) inParagraph() bool {
+	if ht.br.ID != cP {
+		return false
+	}
+	prev, err := ht.br.SiblingN(-1)
+	if err != nil {
+		return false
+	}
+	_, ok := prev.(string)
+	return ok
+}
+
+// NewHeader returns a branch holding a header with level 'n' for the text 's'.
+func NewHeader(s string, n int) *branch.Branch {
+	hdr := strings.TrimSpace(s)
+	b := branch.NewBranch(fmt.Sprintf("h%d", n))
+	b.Info = "id=\"" + Plain(hdr) + "\""
+	b.Add(-1, hdr)
+	return b
+}
+
+// NewHTMLTree returns a pointer to a new HTMLTree struct.
+func NewHTMLTree(s string) HTMLTree {
+	ht := HTMLTree{
+		root: branch.NewBranch(s),
+	}
+	ht.br = ht.root
+	return ht
+}
+
+// HighLite starts or ends fenced code. 's' holds the line with the fence and
+// 'indnt' its indent. The first word of the info string following an opening
+// fence sets the language class of the code.
+func (ht *HTMLTree) HighLite(s string, indnt int) error {
+	var err error
+	// Syntactic hightlighting starts or ends
+	ht.isHighLited = !ht.isHighLited
+	if ht.isHighLited { // starts
+		up := 1
+		if ht.isQuoted {
+			// ends the precoded quote
+			up = 2
+			ht.isQuoted = false
+			ht.quoteEmpty = 0
+		}
+		err = ht.TryParent(up)
+		if err != nil {
+			return err
+		}
+		var info string
+		ht.fence, info = SplitFence(s[indnt:])
+		ht.fenceIndnt = indnt
+		ht.br, _ = ht.br.AddBranch(-1, "pre")
+		ht.br, _ = ht.br.AddBranch(-1, "code")
+		if lang := strings.Fields(info); len(lang) > 0 {
+			ht.br.Info = "class=\"language-" + html.EscapeString(Unescape(lang[0])) + "\""
+			if ht.opts.Highlight {
+				ht.lexer = highlight.Lookup(lang[0])
+			}
+		}
+	} else {
+		ht.HighLiteCode()
+		err = ht.TryParent(2)
+		if err != nil {
+			return err
+		}
+		ht.br, _ = ht.br.AddBranch(-1, "p")
+	}
+	return nil
+}
+
+// HighLiteCode replaces the lines of fenced code in the current branch by
+// the tokens the lexer for its language finds in them. Tokens that aren't
+// plain text are put in a 'span' with the CSS class for their kind.
+func (ht *HTMLTree) HighLiteCode() {
+	if ht.lexer == nil {
+		return
+	}
+
+	var b strings.Builder
+	for _, c := range ht.br.Siblings() {
+		if s, ok := c.(string); ok {
+			b.WriteString(s)
+		}
+	}
+
+	ht.br.RemoveAll()
+	for _, t := range ht.lexer.Tokens(b.String()) {
+		s := html.EscapeString(t.Text)
+		if t.Kind == highlight.Text {
+			ht.br.Add(-1, s)
+			continue
+		}
+		span, _ := ht.br.AddBranch(-1, "span")
+		span.Info = "class=\"" + t.Kind.Class() + "\""
+		span.Add(-1, s)
+	}
+	ht.lexer = nil
+}
+
+// Finish completes the HTML tree after the last line has been added.
+func (ht *HTMLTree) Finish() {
+	if ht.isHighLited {
+		// fenced code without a closing fence
+		ht.HighLiteCode()
+	}
+	if ht.math != nil {
+		// display math without a closing "$$"
+		ht.MathBlock("$$")
+	}
+
+	switch {
+	case ht.list != nil:
+		ht.EndList()
+	case ht.child != nil:
+		ht.EndChild()
+	default:
+		return
+	}
+	ht.closeParagraph()
+}
+
+// HTMLBlock adds the line 's' to a raw HTML block. When 'cond' isn't 0, a new
+// block starts for the start condition 'cond'. The block ends with the line
+// that meets the end condition.
+func (ht *HTMLTree) HTMLBlock(s string, cond int) error {
+	if cond > 0 {
+		ht.closeParagraph()
+		ht.br, _ = ht.root.AddBranch(-1, cRawHTML)
+		ht.htmlCond = cond
+	} else if ht.htmlCond >= 6 && len(strings.TrimSpace(s)) <= 0 {
+		ht.htmlCond = 0
+		ht.Reset()
+		return nil
+	}
+
+	if ht.in.Sn == nil || ht.htmlCond != 2 {
+		// a sanitizer removes comments
+		ht.br.Add(-1, ht.in.SanitizeHTML(strings.TrimRight(s, "\r\n")+"\n"))
+	}
+	if ht.htmlCond < 6 && HTMLBlockEnds(s, ht.htmlCond) {
+		ht.htmlCond = 0
+		ht.Reset()
+	}
+	return nil
+}
+
+// ListItem starts a list item with the line 's', which has a list marker at
+// position 'indnt'. 'nEnd' holds the length of the number of an ordered list
+// item, or 0 for an unordered one. A list ends when the bullet or delimiter
+// changes. When 'afterEmpty' is true, an empty line preceded the item, which
+// makes its list loose. The contents of the item are built by a tree of their
+// own.
+func (ht *HTMLTree) ListItem(s string, indnt, nEnd int, afterEmpty bool) error {
+	marker := s[indnt+nEnd]
+	if ht.list != nil && ht.marker != marker {
+		ht.EndList()
+	}
+
+	if ht.list == nil {
+		ht.closeParagraph()
+		ht.isQuoted = false
+		ht.quoteEmpty = 0
+		ht.tblInfo = TableInfo{}
+
+		ht.AddList(s[indnt:], nEnd)
+		ht.list = ht.br
+		ht.listLoose = false
+		ht.marker = marker
+	} else if afterEmpty {
+		ht.listLoose = true
+	}
+
+	ht.childIndnt = ContentIndent(s, indnt+nEnd+1)
+
+	li, _ := ht.list.AddBranch(-1, cLi)
+	ht.child = ht.newChild(li)
+	if ht.childIndnt > len(s) {
+		return ht.child.Build("")
+	}
+	t := s[ht.childIndnt:]
+	task := len(TaskBox(t)) > 0
+	if task {
+		// escape the brackets, so the marker can't become a link
+		t = "\\[" + t[1:2] + "\\]" + t[3:]
+	}
+	if err := ht.child.Build(t); err != nil {
+		return err
+	}
+	if task {
+		ht.child.CheckBox()
+	}
+	return nil
+}
+
+// CheckBox makes the list item that is the root of the tree a task list item
+// when its text starts with "[ ]", "[x]" or "[X]". These are replaced by a
+// check box. The text must have been detected as a task list item before its
+// inline mark down was translated.
+func (ht *HTMLTree) CheckBox() {
+	if !ht.inParagraph() || ht.br.Len() != 1 {
+		return
+	}
+	sbl, _ := ht.br.SiblingN(0)
+	s := sbl.(string)
+	if box := TaskBox(s); len(box) > 0 {
+		ht.br.Remove(0)
+		ht.br.Add(-1, box+s[3:])
+		ht.root.Info = cTaskItem
+	}
+}
+
+// CountTasks returns the number of checked task list items and the total
+// number of task list items in the tree 'br'.
+func CountTasks(br *branch.Branch) (checked, total int) {
+	for _, sbl := range br.Siblings() {
+		b, ok := sbl.(*branch.Branch)
+		if !ok {
+			continue
+		}
+		if b.ID == cLi && b.Info == cTaskItem {
+			total++
+			if strings.HasPrefix(FirstText(b), cTaskBoxChecked) {
+				checked++
+			}
+		}
+		c, t := CountTasks(b)
+		checked += c
+		total += t
+	}
+	return checked, total
+}
+
+// FirstText returns the first string in the tree 'br', or "" when there is
+// none.
+func FirstText(br *branch.Branch) string {
+	for _, sbl := range br.Siblings() {
+		switch c := sbl.(type) {
+		case string:
+			return c
+		case *branch.Branch:
+			if s := FirstText(c); len(s) > 0 {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// HeaderText returns the text of the first h1 header directly in 'br',
+// without its inline markup, or "" when there is none.
+func HeaderText(br *branch.Branch) string {
+	for _, sbl := range br.Siblings() {
+		b, ok := sbl.(*branch.Branch)
+		if !ok || b.ID != cH1 {
+			continue
+		}
+		parts := []string{}
+		for _, c := range b.Siblings() {
+			if s, ok := c.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+		s := html.UnescapeString(StripTags(strings.Join(parts, " ")))
+		return strings.Join(strings.Fields(s), " ")
+	}
+	return ""
+}
+
+// DefList returns the definition list for a definition following the current
+// line, or nil when it can't start or continue one. The lines of a paragraph
+// directly before the definition, or before the empty line 'afterEmpty'
+// points at, become its terms. A definition list directly before the
+// paragraph, or the definition, is continued.
+func (ht *HTMLTree) DefList(afterEmpty bool) *branch.Branch {
+	if ht.br.ID != cP {
+		return nil
+	}
+	i, err := ht.root.Index(ht.br)
+	if err != nil {
+		return nil
+	}
+
+	terms := ht.br
+	if ht.br.Len() <= 0 {
+		// an empty paragraph after an empty line or another definition
+		if i <= 0 {
+			return nil
+		}
+		prev, _ := ht.root.SiblingN(i - 1)
+		b, ok := prev.(*branch.Branch)
+		switch {
+		case ok && b.ID == cDl:
+			ht.root.Remove(i)
+			ht.br = b
+			return b
+		case !ok || b.ID != cP || !afterEmpty || !OnlyStrings(b):
+			return nil
+		}
+		ht.root.Remove(i)
+		terms, i = b, i-1
+	} else if afterEmpty || !OnlyStrings(terms) {
+		return nil
+	}
+
+	ht.root.Remove(i)
+	var dl *branch.Branch
+	if i > 0 {
+		prev, _ := ht.root.SiblingN(i - 1)
+		if b, ok := prev.(*branch.Branch); ok && b.ID == cDl {
+			dl = b
+		}
+	}
+	if dl == nil {
+		if i >= ht.root.Len() {
+			i = -1
+		}
+		dl, _ = ht.root.AddBranch(i, cDl)
+	}
+	for _, t := range terms.Siblings() {
+		dt, _ := dl.AddBranch(-1, cDt)
+		dt.Add(-1, strings.TrimSuffix(t.(string), "<br/>\n"))
+	}
+	ht.br = dl
+	return dl
+}
+
+// OnlyStrings tests if all siblings of 'br' are strings.
+func OnlyStrings(br *branch.Branch) bool {
+	for _, sbl := range br.Siblings() {
+		if _, ok := sbl.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Definition starts a definition in the definition list 'dl' with the line
+// 's', which has a ':' at position 'indnt'. When 'loose' is true, its contents
+// are put in paragraphs. They are built by a tree of their own.
+func (ht *HTMLTree) Definition(dl *branch.Branch, s string, indnt int, loose bool) error {
+	ht.hardBr = false
+	ht.tblInfo = TableInfo{}
+	ht.childIndnt = ContentIndent(s, indnt+1)
+	ht.ddLoose = loose
+
+	dd, _ := dl.AddBranch(-1, cDd)
+	ht.child = ht.newChild(dd)
+	return ht.child.Build(s[ht.childIndnt:])
+}
+
+// AddList adds a list starting with the item 's' and makes it the current
+// branch. 'nEnd' holds the length of the number of an ordered list item, or 0
+// for an unordered one.
+func (ht *HTMLTree) AddList(s string, nEnd int) {
+	if nEnd <= 0 {
+		ht.br, _ = ht.br.AddBranch(-1, cUl)
+		return
+	}
+	ht.br, _ = ht.br.AddBranch(-1, cOl)
+	if start, _ := strconv.Atoi(s[:nEnd]); start != 1 {
+		ht.br.Info = fmt.Sprintf("start=\"%d\"", start)
+	}
+}
+
+// EndList ends the open list. The paragraphs in the items of a tight list are
+// replaced by their text.
+func (ht *HTMLTree) EndList() {
+	if ht.child != nil {
+		ht.EndChild()
+	}
+	for _, sbl := range ht.list.Siblings() {
+		if li, ok := sbl.(*branch.Branch); ok {
+			TidyItem(li, !ht.listLoose)
+		}
+	}
+	ht.list = nil
+	ht.Reset()
+}
+
+// TidyItem removes the empty paragraphs from the list item 'li'. When 'tight'
+// is true, the other paragraphs are replaced by their contents.
+func TidyItem(li *branch.Branch, tight bool) {
+	sbls := li.Siblings()
+	li.RemoveAll()
+	for _, c := range sbls {
+		b, ok := c.(*branch.Branch)
+		switch {
+		case !ok || b.ID != cP:
+			li.Add(-1, c)
+		case tight:
+			li.Add(-1, b.Siblings()...)
+		case b.Len() > 0:
+			li.Add(-1, b)
+		}
+	}
+}
+
+// Quote makes the lines show up as pre coded text 's'.
+func (ht *HTMLTree) Quote(line string) error {
+	if ht.br.ID == "p" {
+		if err := ht.TryParent(1); err != nil {
+			return err
+		}
+		ht.br, _ = ht.br.AddBranch(-1, "pre")
+		ht.br, _ = ht.br.AddBranch(-1, "code")
+		ht.isQuoted = true
+	}
+	for ; ht.quoteEmpty > 0; ht.quoteEmpty-- {
+		ht.br.Add(-1, "\n")
+	}
+	ht.br.Add(-1, html.EscapeString(line[4:]))
+	return nil
+}
+
+// Reset resets the current tree to a new paragraph in 'root'
+func (ht *HTMLTree) Reset() {
+	ht.br = ht.root
+	ht.isHighLited = false
+	ht.isQuoted = false
+	ht.quoteEmpty = 0
+	ht.hardBr = false
+	ht.tblInfo = TableInfo{}
+	ht.br, _ = ht.root.AddBranch(-1, "p")
+}
+
+// Rule adds a horizontal rule, which ends all open blocks.
+func (ht *HTMLTree) Rule() {
+	ht.closeParagraph()
+	ht.br.Add(-1, NewRule())
+	ht.Reset()
+}
+
+// NewRule returns a branch holding a horizontal rule.
+func NewRule() *branch.Branch {
+	b := branch.NewBranch(cHr)
+	b.Add(-1, "")
+	return b
+}
+
+// closeParagraph ends the current block, which is removed when it is empty,
+// so the next block is added to the root.
+func (ht *HTMLTree) closeParagraph() {
+	b := ht.br
+	ht.br = ht.root
+	ht.RmIfEmpty(b)
+}
+
+// RmIfEmpty removes the branch 'brnch' if it is empty.
+func (ht *HTMLTree) RmIfEmpty(brnch *branch.Branch) error {
+	if brnch != ht.root && brnch.Len() <= 0 {
+		// mostly it is the last sibling, so avoid searching for it
+		if last, err := ht.br.SiblingN(-1); err == nil && last == brnch {
+			_, err = ht.br.Remove(-1)
+			return err
+		}
+		if i, err := ht.br.Index(brnch); err == nil {
+			_, err = ht.br.Remove(i)
+			return err
+		}
+	}
+	return nil
+}
+
+// TblRow adds a table row for the line 'line'. 's' holds its inline
+// translation, which is added when it isn't a table row. 'refs' holds the
+// labels of the footnote references in 's', which then count.
+func (ht *HTMLTree) TblRow(line, s string, refs []string) error {
+	var err error
+	b := TRow(line, false, &(ht.tblInfo), &ht.in)
+	if b != nil {
+		ht.br.Add(-1, b)
+	} else {
+		// end of table
+		err = ht.TryParent(1)
+		if err != nil {
+			return err
+		}
+		ht.tblInfo = TableInfo{}
+		ht.in.Notes.Redo(refs)
+		ht.AddText(s)
+	}
+	return nil
+}
+
+// TRow returns a branch holding a table row. If hdr is true, a table header
+// is asumed. tblInfo holds the TableInfo for the table collumns , 'in'
+// translates the contents of the cells. A '|' in a cell must be escaped.
+func TRow(s string, hdr bool, tblInfo *TableInfo, in *Inliner) *branch.Branch {
+	cols := SplitCells(strings.TrimSpace(s))
+
+	l := len(cols)
+	if l < 3 || len(cols[0]) > 0 || len(cols[l-1]) > 0 {
+		return nil
+	}
+
+	cols = cols[1 : l-1]
+	l = l - 2
+	if l > len(*tblInfo) {
+		l = len(*tblInfo)
+		cols = cols[:l]
+	}
+	rslt := &branch.Branch{ID: "tr"}
+	br := rslt
+
+	tag := "td"
+	if hdr {
+		tag = "th"
+	}
+
+	for i, col := range cols {
+		br, _ = br.AddBranch(-1, tag)
+
+		if (*tblInfo)[i] > 0 {
+			a := "left"
+			switch (*tblInfo)[i] {
+			case right:
+				a = "right"
+			case center:
+				a = "center"
+			}
+			br.Info = fmt.Sprintf("style=\"text-align: %s\"", a)
+		}
+
+		br.Add(-1, in.Inline(strings.TrimSpace(strings.Replace(col, "\\|", "|", -1))))
+		br, _ = br.Parent(1)
+	}
+	return rslt
+}
+
+// TryParent goes safely to the 'n'-th parent of the current branch.
+func (ht *HTMLTree) TryParent(n int) error {
+	var err error
+	if ht.br == nil {
+		ht.Reset()
+		return nil
+	}
+	for n > 0 {
+		if ht.br == ht.root {
+			return nil
+		}
+		b := ht.br
+		ht.br, err = ht.br.Parent(1)
+		if err != nil {
+			err = fmt.Errorf("TryParent (%d): %s", ht.sCount, err)
+			return err
+		}
+
+		ht.RmIfEmpty(b)
+		n--
+	}
+	return nil
+}
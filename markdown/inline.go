@@ -0,0 +1,451 @@
+//
+// inline.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// translation of inline mark down definitions.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/FrankStorbeck/md2html/mathml"
+)
+
+// cMark encloses the index of stashed html code.
+const cMark = "\x00"
+
+// Inliner translates inline mark down definitions to their html equivalents.
+type Inliner struct {
+	LinkURLs bool       // when true bare URLs become links
+	NoHTML   bool       // when true raw HTML is escaped
+	Notes    *Notes     // footnote definitions, nil if there are none
+	Refs     Refs       // link reference definitions
+	Sn       *Sanitizer // sanitizer for raw HTML, nil if not safe
+	stash    []string   // html code that must not be changed any further
+}
+
+// Inline translates all inline mark down definitions to their html
+// equivalents. Text is escaped and raw HTML is sanitised.
+func (in *Inliner) Inline(s string) string {
+	in.stash = in.stash[:0]
+	s = strings.Replace(s, cMark, "\uFFFD", -1)
+
+	// order is important here
+	s = in.InlineCodes(s)
+	s = in.Math(s)
+	s = in.Escapes(s)
+	s = in.Autolinks(s)
+	s = in.RawHTML(s)
+	s = in.Entities(s)
+	s = in.Footnotes(s)
+	s = in.Images(s)
+	s = in.Links(s)
+	if in.LinkURLs {
+		s = in.BareURLs(s)
+	}
+	s = StrongEmDel(EscapeHTML(s))
+	return in.unstash(s)
+}
+
+// Images translates mark down image definitions to their html equivalents.
+// When the sanitizer rejects the URL of an image, only its alternative text
+// is kept.
+func (in *Inliner) Images(s string) string {
+	for i := strings.Index(s, "!["); i >= 0; {
+		j := strings.Index(s[i:], "]")
+		if j <= 0 {
+			break
+		}
+		alt := s[i+2 : i+j]
+		if u, title, end, ok := in.dest(s, alt, i+j); ok {
+			u = in.plain(u)
+			if !in.Sn.URL(u) {
+				return s[:i] + alt + in.Images(s[end:])
+			}
+			alt = StripTags(in.unstash(EscapeHTML(alt)))
+			return s[:i] + in.keep("<img src=\""+urlAttr(u)+"\" alt=\""+alt+
+				"\""+titleAttr(in.plain(title))+"/>") + in.Images(s[end:])
+		}
+		k := strings.Index(s[i+2:], "![")
+		if k < 0 {
+			break
+		}
+		i += k + 2
+	}
+	return s
+}
+
+// Entities translates the valid entity and numeric character references in
+// 's' to their escaped html equivalents.
+func (in *Inliner) Entities(s string) string {
+	if !strings.Contains(s, "&") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '&' {
+			if r, n := Entity(s[i:]); n > 0 {
+				b.WriteString(in.keep(html.EscapeString(r)))
+				i += n - 1
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Escapes translates ASCII punctuation runes escaped by a '\' to their html
+// equivalents, so they lose their mark down meaning.
+func (in *Inliner) Escapes(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && IsPunctuation(s[i+1]) {
+			b.WriteString(in.keep(html.EscapeString(s[i+1 : i+2])))
+			i++
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// InlineCodes translates mark down code definitions to their html
+// equivalents. A '`' escaped by a '\' doesn't start code.
+func (in *Inliner) InlineCodes(s string) string {
+	l := len(s)
+	i := strings.Index(s, "`")
+	for i > 0 && IsEscaped(s, i) {
+		k := strings.Index(s[i+1:], "`")
+		if k < 0 {
+			return s
+		}
+		i += k + 1
+	}
+	if i >= 0 && l > i+2 {
+		if j := strings.Index(s[i+1:], "`"); j > 0 {
+			return s[:i] + in.keep("<code>"+html.EscapeString(s[i+1:i+j+1])+
+				"</code>") + in.InlineCodes(s[i+j+2:])
+		}
+	}
+	return s
+}
+
+// Math translates TeX math like $x$ and $$x$$ to MathML. A '$' escaped by a
+// '\' doesn't start math. The math between single '$' runes must not start or
+// end with white space and the closing '$' must not be followed by a digit, so
+// amounts of money aren't taken for math.
+func (in *Inliner) Math(s string) string {
+	for i := strings.Index(s, "$"); i >= 0; {
+		next := i + 1 // where to look for the next '$'
+		if !IsEscaped(s, i) {
+			if strings.HasPrefix(s[i:], "$$") {
+				if j := strings.Index(s[i+2:], "$$"); j > 0 && !strings.Contains(s[i+2:i+2+j], cMark) {
+					return s[:i] + in.keep(mathml.Convert(s[i+2:i+2+j], true)) + in.Math(s[i+j+4:])
+				}
+				next = i + 2
+			} else if j := MathEnd(s[i+1:]); j > 0 && !strings.Contains(s[i+1:i+1+j], cMark) {
+				return s[:i] + in.keep(mathml.Convert(s[i+1:i+1+j], false)) + in.Math(s[i+j+2:])
+			}
+		}
+		if next >= len(s) {
+			break
+		}
+		k := strings.Index(s[next:], "$")
+		if k < 0 {
+			break
+		}
+		i = next + k
+	}
+	return s
+}
+
+// MathEnd returns the position of the '$' closing the inline math at the
+// start of 's', or 0 when there is none. It is the first '$' that isn't
+// escaped.
+func MathEnd(s string) int {
+	if len(s) <= 0 || s[0] == ' ' || s[0] == '\t' {
+		return 0
+	}
+	for j := 1; j < len(s); j++ {
+		if s[j] != '$' || IsEscaped(s, j) {
+			continue
+		}
+		if s[j-1] == ' ' || s[j-1] == '\t' || j+1 < len(s) && isDigit(s[j+1]) {
+			return 0
+		}
+		return j
+	}
+	return 0
+}
+
+// Links translates mark down link definitions to their html equivalents.
+// These are inline links like [text](url), or references to a link reference
+// definition like [text][label], [label][] and [label]. When the sanitizer
+// rejects the URL of a link, only its text is kept.
+func (in *Inliner) Links(s string) string {
+	for i := strings.Index(s, "["); i >= 0; {
+		j := strings.Index(s[i:], "]")
+		if j <= 0 {
+			break
+		}
+		txt := s[i+1 : i+j]
+		if u, title, end, ok := in.dest(s, txt, i+j); ok {
+			u = in.plain(u)
+			if !in.Sn.URL(u) {
+				return s[:i] + txt + in.Links(s[end:])
+			}
+			return s[:i] + in.keep("<a href=\""+urlAttr(u)+"\""+titleAttr(in.plain(title))+
+				">"+StrongEmDel(EscapeHTML(txt))+"</a>") + in.Links(s[end:])
+		}
+		k := strings.Index(s[i+1:], "[")
+		if k < 0 {
+			break
+		}
+		i += k + 1
+	}
+	return s
+}
+
+// RawHTML sanitises the raw HTML in 's': tags, comments, processing
+// instructions, declarations and CDATA sections. When raw HTML is disabled,
+// it is left as it is, so it will be escaped.
+func (in *Inliner) RawHTML(s string) string {
+	if in.NoHTML {
+		return s
+	}
+	for i := strings.Index(s, "<"); i >= 0; {
+		if n := DestLen(s, i); n > 0 {
+			// a link destination between '<' and '>' isn't a tag
+			i += n - 1
+		} else if n := HTMLTagLen(s[i:]); n > 0 {
+			return s[:i] + in.keep(in.Sn.Tag(s[i:i+n])) + in.RawHTML(s[i+n:])
+		}
+		k := strings.Index(s[i+1:], "<")
+		if k < 0 {
+			break
+		}
+		i += k + 1
+	}
+	return s
+}
+
+// SanitizeHTML returns the sanitised version of the raw HTML code 's'. Its
+// text is escaped. Without a sanitizer 's' is returned as it is.
+func (in *Inliner) SanitizeHTML(s string) string {
+	if in.Sn == nil {
+		return s
+	}
+	in.stash = in.stash[:0]
+	return in.unstash(EscapeHTML(in.Entities(in.RawHTML(s))))
+}
+
+// dest returns the URL and the title of a link or image with text 'txt',
+// which is closed by the ']' at position 'j' in 's', and the position
+// following it. When it isn't followed by a destination and 'txt' isn't a
+// defined label, 'ok' will be false.
+func (in *Inliner) dest(s, txt string, j int) (u, title string, end int, ok bool) {
+	label := txt
+	end = j + 1
+
+	switch rest := s[j+1:]; {
+	case strings.HasPrefix(rest, "("):
+		u, title, n, ok := ParseDest(rest[1:])
+		if !ok || n+1 >= len(rest) || rest[n+1] != ')' {
+			return "", "", 0, false
+		}
+		return u, title, j + n + 3, true
+
+	case strings.HasPrefix(rest, "["):
+		k := strings.Index(rest, "]")
+		if k <= 0 {
+			return "", "", 0, false
+		}
+		if k > 1 {
+			label = rest[1:k]
+		}
+		end = j + k + 2
+	}
+
+	r, ok := in.Refs.Lookup(StripTags(in.unstash(label)))
+	if !ok {
+		return "", "", 0, false
+	}
+	return r.URL, r.Title, end, true
+}
+
+// keep stashes the html code 'h', so it won't be changed any further, and
+// returns a reference to it.
+func (in *Inliner) keep(h string) string {
+	in.stash = append(in.stash, h)
+	return cMark + strconv.Itoa(len(in.stash)-1) + cMark
+}
+
+// plain returns the text 's' with the references to stashed html code
+// replaced by the code translated back to plain text, so it can be escaped as
+// an attribute.
+func (in *Inliner) plain(s string) string {
+	if !strings.Contains(s, cMark) {
+		return s
+	}
+
+	parts := strings.Split(s, cMark)
+	for i := 1; i < len(parts); i += 2 {
+		parts[i] = html.UnescapeString(in.unstash(cMark + parts[i] + cMark))
+	}
+	return strings.Join(parts, "")
+}
+
+// unstash replaces the references to stashed html code in 's' by the code,
+// which may hold references itself.
+func (in *Inliner) unstash(s string) string {
+	if !strings.Contains(s, cMark) {
+		return s
+	}
+
+	var b strings.Builder
+	for i, p := range strings.Split(s, cMark) {
+		if i%2 == 1 {
+			if n, err := strconv.Atoi(p); err == nil && n < len(in.stash) {
+				b.WriteString(in.unstash(in.stash[n]))
+				continue
+			}
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// ParseDest parses the destination of a link, optionally followed by a title,
+// at the start of 's'. The destination is either enclosed by '<' and '>', or
+// it holds no spaces and only balanced parentheses. The title is enclosed by
+// double quotes, single quotes or parentheses. It returns the destination,
+// the title and the number of bytes parsed, including trailing white space.
+// When 's' doesn't start with a destination, 'ok' is false.
+func ParseDest(s string) (u, title string, n int, ok bool) {
+	i := skipSpace(s, 0)
+
+	if i < len(s) && s[i] == '<' {
+		j := i + 1
+		for ; j < len(s) && s[j] != '>' && s[j] != '<'; j++ {
+			if s[j] == '\\' && j+1 < len(s) {
+				j++
+			}
+		}
+		if j >= len(s) || s[j] != '>' {
+			return "", "", 0, false
+		}
+		u, i = s[i+1:j], j+1
+	} else {
+		j, depth := i, 0
+	loop:
+		for ; j < len(s); j++ {
+			switch c := s[j]; {
+			case c == '\\' && j+1 < len(s):
+				j++
+			case c == '(':
+				depth++
+			case c == ')':
+				if depth == 0 {
+					break loop
+				}
+				depth--
+			case c <= ' ' && c != cMark[0]:
+				break loop
+			}
+		}
+		if depth != 0 {
+			return "", "", 0, false
+		}
+		u, i = s[i:j], j
+	}
+
+	k := skipSpace(s, i)
+	if k == i || k >= len(s) {
+		return u, "", k, true
+	}
+	closing := map[byte]byte{'"': '"', '\'': '\'', '(': ')'}[s[k]]
+	if closing == 0 {
+		return u, "", k, true
+	}
+
+	j := k + 1
+	for ; j < len(s) && s[j] != closing; j++ {
+		if s[j] == '\\' && j+1 < len(s) {
+			j++
+		}
+	}
+	if j >= len(s) {
+		return "", "", 0, false
+	}
+	return u, s[k+1 : j], skipSpace(s, j+1), true
+}
+
+// DestLen returns the length of the destination of a link or image, and its
+// title, when it starts with the '<' at position 'i' in 's', or 0 when there
+// is none.
+func DestLen(s string, i int) int {
+	if !strings.HasSuffix(strings.TrimRight(s[:i], " \t\r\n"), "](") {
+		return 0
+	}
+	_, _, n, ok := ParseDest(s[i:])
+	if !ok || i+n >= len(s) || s[i+n] != ')' {
+		return 0
+	}
+	return n
+}
+
+// skipSpace returns the position of the first rune in 's', starting at
+// position 'i', that isn't a space, a tab or a line end.
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// titleAttr returns the title attribute for the title 'title', or an empty
+// string when it is empty.
+func titleAttr(title string) string {
+	if len(title) <= 0 {
+		return ""
+	}
+	return " title=\"" + EscapeHTML(title) + "\""
+}
+
+// urlAttr returns the value of an attribute for the URL 'u'.
+func urlAttr(u string) string {
+	return EscapeHTML(strings.Replace(u, " ", "%20", -1))
+}
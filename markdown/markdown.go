@@ -0,0 +1,273 @@
+//
+// markdown.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// markdown translates mark down text into its HTML equivalent.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package markdown translates mark down text into an HTML document.
+package markdown
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/FrankStorbeck/md2html/branch"
+)
+
+const (
+	cBlockQuote     = "blockquote"
+	cBody           = "body"
+	cCode           = "code"
+	cDd             = "dd"
+	cDiv            = "div"
+	cDl             = "dl"
+	cDt             = "dt"
+	cHead           = "head"
+	cHTML           = "html"
+	cH1             = "h1"
+	cH2             = "h2"
+	cH3             = "h3"
+	cH4             = "h4"
+	cH5             = "h5"
+	cH6             = "h6"
+	cHr             = "hr"
+	cLi             = "li"
+	cLink           = "link"
+	cMeta           = "meta"
+	cOl             = "ol"
+	cP              = "p"
+	cPre            = "pre"
+	cQ              = "q"
+	cRawHTML        = "#html" // raw HTML block, rendered without tags
+	cCrLf           = "\r\n"
+	cSection        = "section"
+	cScript         = "script"
+	cStyle          = "style"
+	cTable          = "table"
+	cTaskBox        = "<input type=\"checkbox\" disabled/>"
+	cTaskBoxChecked = "<input type=\"checkbox\" checked disabled/>"
+	cTaskItem       = "class=\"task-list-item\""
+	cTd             = "td"
+	cTh             = "th"
+	cTitle          = "title"
+	cTr             = "tr"
+	cUl             = "ul"
+)
+
+// Options holds the settings for a conversion.
+type Options struct {
+	Allow        map[string][]string // tags and attributes allowed in safe mode
+	Author       string              // author of the HTML document
+	AutoTitle    bool                // when true a missing title is taken from the first h1
+	Autolink     bool                // when true bare URLs become links
+	Description  string              // description of the HTML document
+	FileName     string              // name of the input file, the last resort for AutoTitle
+	Fragment     bool                // when true only the contents of the body are written
+	Highlight    bool                // when true fenced code gets syntax highlighting
+	HighlightCSS string              // style sheet for syntax highlighting
+	Keywords     []string            // keywords for the HTML document
+	Lang         string              // language of the HTML document
+	NoHTML       bool                // when true raw HTML is escaped
+	Safe         bool                // when true raw HTML and unsafe URLs are sanitised
+	SoftBreak    string              // soft line break mode: "space", "newline" or "br"
+	Style        string              // style sheet for the HTML document
+	Stylesheets  []string            // more style sheets for the HTML document
+	Title        string              // title for the HTML document
+}
+
+// BuildHTMLTree returns a pointer to a branch struct with all HTML elements
+// from the mark down text read from 'r', using the settings in 'opts'. Front
+// matter is skipped. In case of an error the tree built so far and the error
+// will be returned.
+func BuildHTMLTree(r io.Reader, opts Options) (*branch.Branch, error) {
+	lines, err := ReadLines(r)
+	if err != nil {
+		return NewHTMLTree(cBody).root, err
+	}
+	_, lines = ParseFrontMatter(lines)
+	return buildHTMLTree(lines, opts), nil
+}
+
+// ReadLines reads all lines from 'r', each including its line end. When an
+// error occured, the lines read so far and the error will be returned.
+func ReadLines(r io.Reader) ([]string, error) {
+	buf := bufio.NewReader(r)
+
+	lines := []string{}
+	for {
+		line, err := buf.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return lines, err
+		}
+		lines = append(lines, line)
+		if err == io.EOF {
+			return lines, nil
+		}
+	}
+}
+
+// buildHTMLTree returns a pointer to a branch struct with all HTML elements
+// from the mark down text in 'lines', using the settings in 'opts'.
+func buildHTMLTree(lines []string, opts Options) *branch.Branch {
+	st := NewHTMLTree(cBody)
+	st.opts = opts
+	st.in.LinkURLs = opts.Autolink
+	st.in.NoHTML = opts.NoHTML
+	if opts.Safe {
+		st.in.Sn = NewSanitizer(opts.Allow)
+	}
+	st.br, _ = st.root.AddBranch(-1, cP)
+
+	// link reference definitions may follow their use, so collect them first
+	st.in.Notes, lines = CollectNotes(lines)
+	st.in.Refs, lines = CollectRefs(lines)
+
+	for _, line := range lines {
+		st.Build(line)
+	}
+	st.Finish()
+	st.Footnotes()
+
+	return st.root
+}
+
+// Convert reads mark down text from 'r' and writes its HTML equivalent to 'w'.
+// When an error occured, it will be returned.
+func Convert(r io.Reader, w io.Writer, opts Options) error {
+	_, err := ConvertFrontMatter(r, w, opts)
+	return err
+}
+
+// ConvertFrontMatter works like Convert, but also returns the front matter
+// of the mark down text, so keys that don't end up in the HTML head can be
+// used elsewhere, like in a template.
+func ConvertFrontMatter(r io.Reader, w io.Writer, opts Options) (FrontMatter, error) {
+	soft := " "
+	if len(opts.SoftBreak) > 0 {
+		var ok bool
+		if soft, ok = SoftBreaks[opts.SoftBreak]; !ok {
+			return nil, fmt.Errorf("unknown soft break mode %q", opts.SoftBreak)
+		}
+	}
+
+	lines, err := ReadLines(r)
+	if err != nil {
+		return nil, fmt.Errorf("building HTML tree: %s", err)
+	}
+
+	// front matter supplies the settings for the HTML head
+	fm, lines := ParseFrontMatter(lines)
+	opts = fm.Options(opts)
+	body := buildHTMLTree(lines, opts)
+
+	if opts.Fragment {
+		return fm, RenderSiblings(w, body, soft)
+	}
+
+	if opts.AutoTitle && len(opts.Title) <= 0 {
+		opts.Title = HeaderText(body)
+		if len(opts.Title) <= 0 && len(opts.FileName) > 0 {
+			name := filepath.Base(opts.FileName)
+			opts.Title = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+	}
+
+	head := opts.Header()
+	if checked, total := CountTasks(body); total > 0 {
+		// progress of the task list items
+		for _, m := range []struct {
+			name string
+			n    int
+		}{{"tasks-checked", checked}, {"tasks-total", total}} {
+			meta, _ := head.AddBranch(-1, cMeta)
+			meta.Info = fmt.Sprintf("name=\"%s\" content=\"%d\"", m.name, m.n)
+			meta.Add(-1, "")
+		}
+	}
+
+	doc := NewHTMLTree(cHTML)
+	if len(opts.Lang) > 0 {
+		doc.root.Info = fmt.Sprintf("lang=\"%s\"", html.EscapeString(opts.Lang))
+	}
+	doc.root.Add(-1, head)
+	doc.root.Add(-1, body)
+
+	return fm, Render(w, doc.root, soft)
+}
+
+// Header returns a branch holding HTML head data.
+func (opts Options) Header() *branch.Branch {
+	head := branch.NewBranch(cHead)
+
+	if len(opts.Title) > 0 {
+		title, _ := head.AddBranch(-1, cTitle)
+		title.Add(-1, html.EscapeString(opts.Title))
+	}
+
+	meta, _ := head.AddBranch(-1, cMeta)
+	meta.Info = "charset=\"utf-8\""
+	meta.Add(-1, "")
+
+	meta, _ = head.AddBranch(-1, cMeta)
+	meta.Info = "name=\"generator\" content=\"md2html\""
+	meta.Add(-1, "")
+
+	for _, m := range []struct{ name, content string }{
+		{"description", opts.Description},
+		{"author", opts.Author},
+		{"keywords", strings.Join(opts.Keywords, ", ")},
+	} {
+		if len(m.content) > 0 {
+			meta, _ = head.AddBranch(-1, cMeta)
+			meta.Info = fmt.Sprintf("name=\"%s\" content=\"%s\"", m.name,
+				html.EscapeString(m.content))
+			meta.Add(-1, "")
+		}
+	}
+
+	meta, _ = head.AddBranch(-1, cMeta)
+	meta.Info = "http-equiv=\"Content-Style-Type\" content=\"text/css\""
+	meta.Add(-1, "")
+
+	sheets := append([]string{opts.Style}, opts.Stylesheets...)
+	for _, s := range append(sheets, opts.HighlightCSS) {
+		if len(s) > 0 {
+			style, _ := head.AddBranch(-1, cLink)
+			style.Info = fmt.Sprintf("rel=\"stylesheet\" href=\"%s\" type=\"text/css\"",
+				html.EscapeString(s))
+			style.Add(-1, "")
+		}
+	}
+
+	return head
+}
@@ -0,0 +1,1326 @@
+//
+// markdown_test.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// tests for the markdown package.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/FrankStorbeck/md2html/mathml"
+)
+
+func TestStyling(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "", want: ""},
+		{s: "**bb**", want: "<strong>bb</strong>"},
+		{s: "xx**bb**", want: "xx<strong>bb</strong>"},
+		{s: "**bb**yy", want: "<strong>bb</strong>yy"},
+		{s: "xx**bb**yy", want: "xx<strong>bb</strong>yy"},
+		{s: "**pp", want: "**pp"},
+		{s: "xx**pp", want: "xx**pp"},
+		{s: "**ppyy", want: "**ppyy"},
+		{s: "xx__ppyy", want: "xx__ppyy"},
+		{s: "**bb**xx**bb**__bb__**bb**yy", want: "<strong>bb</strong>xx<strong>bb</strong><strong>bb</strong><strong>bb</strong>yy"},
+		{s: "**bb***bb****", want: "<strong>bb</strong><em>bb</em>***"},
+		{s: "snake_case_name", want: "snake_case_name"},
+		{s: "a*b*c", want: "a<em>b</em>c"},
+		{s: "2 * 3 * 4", want: "2 * 3 * 4"},
+		{s: "***strong em***", want: "<em><strong>strong em</strong></em>"},
+		{s: "*foo**bar**baz*", want: "<em>foo<strong>bar</strong>baz</em>"},
+		{s: "*foo**bar*", want: "<em>foo**bar</em>"},
+		{s: "_foo_bar_", want: "<em>foo_bar</em>"},
+		{s: "*foo bar *", want: "*foo bar *"},
+		{s: "* item", want: "* item"},
+		{s: "__foo, __bar__, baz__", want: "<strong>foo, <strong>bar</strong>, baz</strong>"},
+		{s: "~bb~ ~~~bb~~~", want: "~bb~ ~~~bb~~~"},
+		{s: "~~bb~~", want: "<del>bb</del>"},
+		{s: "xx~~bb~~", want: "xx<del>bb</del>"},
+		{s: "~~bb~~yy", want: "<del>bb</del>yy"},
+	}
+	for _, tst := range tests {
+		got := StrongEmDel(tst.s)
+		if got != tst.want {
+			t.Errorf("StrongEmDel(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "a \\* \\_ \\\\ b", want: "a * _ \\ b"},
+		{s: "\\a \\é U+002A", want: "\\a \\é U+002A"},
+		{s: "a\\", want: "a\\"},
+	}
+	for _, tst := range tests {
+		if got := Unescape(tst.s); got != tst.want {
+			t.Errorf("Unescape(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestEntity(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+		n    int
+	}{
+		{s: "&copy; x", want: "©", n: 6},
+		{s: "&#169;", want: "©", n: 6},
+		{s: "&#xA9;", want: "©", n: 6},
+		{s: "&#X22;", want: "\"", n: 6},
+		{s: "&ngE;", want: "≧̸", n: 5},
+		{s: "&#0;", want: "\uFFFD", n: 4},
+		{s: "&#xD800;", want: "\uFFFD", n: 8},
+		{s: "&#12345678;", n: 0},
+		{s: "&foo;", n: 0},
+		{s: "&copy", n: 0},
+		{s: "& copy;", n: 0},
+		{s: "&notit;", n: 0},
+		{s: "&ampfoo;", n: 0},
+		{s: "&copyx;", n: 0},
+		{s: "&notin;", want: "∉", n: 7},
+		{s: "&semi;", want: ";", n: 6},
+	}
+	for _, tst := range tests {
+		if got, n := Entity(tst.s); got != tst.want || n != tst.n {
+			t.Errorf("Entity(%q) returns %q, %d, should be %q, %d", tst.s, got, n, tst.want, tst.n)
+		}
+	}
+}
+
+func TestSplitCells(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{s: "| a | b |", want: []string{"", " a ", " b ", ""}},
+		{s: "| a \\| b |", want: []string{"", " a \\| b ", ""}},
+		{s: "| a \\\\| b |", want: []string{"", " a \\\\", " b ", ""}},
+	}
+	for _, tst := range tests {
+		if got := SplitCells(tst.s); !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("SplitCells(%q) generates %q, should be %q", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestSplitFence(t *testing.T) {
+	tests := []struct {
+		s     string
+		fence string
+		info  string
+	}{
+		{s: "", fence: "", info: ""},
+		{s: "``", fence: "", info: ""},
+		{s: "```", fence: "```", info: ""},
+		{s: "~~~~ go ", fence: "~~~~", info: "go"},
+		{s: "``` a`b", fence: "", info: ""},
+		{s: "~~~ a`b", fence: "~~~", info: "a`b"},
+		{s: "---", fence: "", info: ""},
+	}
+
+	for _, tst := range tests {
+		fence, info := SplitFence(tst.s)
+		if fence != tst.fence || info != tst.info {
+			t.Errorf("'SplitFence(%q)' generates: %q, %q, should be: %q, %q",
+				tst.s, fence, info, tst.fence, tst.info)
+		}
+	}
+}
+
+func TestOlnlyRunes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: "", want: false},
+		{s: "==", want: false},
+		{s: "===", want: true},
+		{s: "=======", want: true},
+		{s: "=======x", want: false},
+		{s: "====x==", want: false},
+	}
+
+	for _, tst := range tests {
+		got := OnlyRunes(tst.s, '=')
+		if got != tst.want {
+			t.Errorf("'OnlyRunes(%q, '=')' generates: %t, should be: %t",
+				tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestIsThematicBreak(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: "", want: false},
+		{s: "--", want: false},
+		{s: "---", want: true},
+		{s: "***\n", want: true},
+		{s: "_____", want: true},
+		{s: " - - -", want: true},
+		{s: "*\t*  *", want: true},
+		{s: "+++", want: false},
+		{s: "-*-", want: false},
+		{s: "--- a", want: false},
+	}
+
+	for _, tst := range tests {
+		got := IsThematicBreak(tst.s)
+		if got != tst.want {
+			t.Errorf("'IsThematicBreak(%q)' generates: %t, should be: %t",
+				tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestCountLeading(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{s: "", want: 0},
+		{s: "x", want: 0},
+		{s: "#x", want: 1},
+		{s: "######x", want: 6},
+		{s: "#######x", want: 0},
+		{s: " #####", want: 0},
+	}
+
+	for _, tst := range tests {
+		n := CountLeading(tst.s, '#', 6)
+		if n != tst.want {
+			t.Errorf("'CountLeading(%q)' generates: %d, should be: %d",
+				tst.s, n, tst.want)
+		}
+	}
+}
+
+func TestHardBreak(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "a", want: ""},
+		{s: "a ", want: ""},
+		{s: "a  \n", want: "  "},
+		{s: "a   ", want: "   "},
+		{s: "a\\", want: "\\"},
+		{s: "a\\\\", want: ""},
+		{s: "   ", want: ""},
+	}
+
+	for _, tst := range tests {
+		if got := HardBreak(tst.s); got != tst.want {
+			t.Errorf("HardBreak(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestIsDefMarker(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: ": a", want: true},
+		{s: ":\ta", want: true},
+		{s: ":a", want: false},
+		{s: ":  ", want: false},
+		{s: "a: b", want: false},
+	}
+
+	for _, tst := range tests {
+		if got := IsDefMarker(tst.s); got != tst.want {
+			t.Errorf("IsDefMarker(%q) generates:\n%t\nshould be:\n%t\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestAlertType(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "[!NOTE]", want: "note"},
+		{s: " [!Warning] ", want: "warning"},
+		{s: "[!caution]", want: "caution"},
+		{s: "[!FOO]", want: ""},
+		{s: "[NOTE]", want: ""},
+		{s: "[!NOTE] a", want: ""},
+	}
+
+	for _, tst := range tests {
+		if got := AlertType(tst.s); got != tst.want {
+			t.Errorf("AlertType(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestSplitAdmonition(t *testing.T) {
+	tests := []struct {
+		s     string
+		fence string
+		typ   string
+		title string
+	}{
+		{s: ":::warning Mind the gap", fence: ":::", typ: "warning", title: "Mind the gap"},
+		{s: ":::: Tip", fence: "::::", typ: "tip", title: "Tip"},
+		{s: ":::my-note", fence: ":::", typ: "my-note", title: "My-note"},
+		{s: ":::"},
+		{s: "::note"},
+		{s: ":::a<b"},
+	}
+
+	for _, tst := range tests {
+		fence, typ, title := SplitAdmonition(tst.s)
+		if fence != tst.fence || typ != tst.typ || title != tst.title {
+			t.Errorf("SplitAdmonition(%q) generates:\n%q %q %q\nshould be:\n%q %q %q\n",
+				tst.s, fence, typ, title, tst.fence, tst.typ, tst.title)
+		}
+	}
+}
+
+func TestOrderedListMarker(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{s: "1. a", want: 1},
+		{s: "12) a", want: 2},
+		{s: "1.", want: 1},
+		{s: "1.a", want: 0},
+		{s: "a. b", want: 0},
+		{s: ". b", want: 0},
+		{s: "123456789. a", want: 9},
+		{s: "1234567890. a", want: 0},
+	}
+
+	for _, tst := range tests {
+		if got := OrderedListMarker(tst.s); got != tst.want {
+			t.Errorf("OrderedListMarker(%q) generates:\n%d\nshould be:\n%d\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		s    []string
+		want string
+	}{
+		// Headers
+		{s: []string{"hdr1", "==="}, want: "r{h1:id=\"hdr1\"{hdr1} p{}}"},
+		{s: []string{"hdr2", "---"}, want: "r{h2:id=\"hdr2\"{hdr2} p{}}"},
+		{s: []string{"aa", "# hdr1", "bb"}, want: "r{p{aa} h1:id=\"hdr1\"{hdr1} p{bb}}"},
+		{s: []string{"aa", "### hdr3", "bb"}, want: "r{p{aa} h3:id=\"hdr3\"{hdr3} p{bb}}"},
+		{s: []string{"###### hdr6"}, want: "r{h6:id=\"hdr6\"{hdr6} p{}}"},
+		{s: []string{"####### hdr7"}, want: "r{p{####### hdr7}}"},
+
+		// Horizontal rules
+		{s: []string{"---"}, want: "r{hr{} p{}}"},
+		{s: []string{"aa", "", "---", "bb"}, want: "r{p{aa} hr{} p{bb}}"},
+		{s: []string{"aa", "***", "bb"}, want: "r{p{aa} hr{} p{bb}}"},
+		{s: []string{"aa", "- - -"}, want: "r{p{aa} hr{} p{}}"},
+		{s: []string{" _ _ _ ", "==="}, want: "r{hr{} p{===}}"},
+		{s: []string{"    ***"}, want: "r{pre{code{***}}}"},
+		{s: []string{"* a", "* * *", "* b"}, want: "r{ul{li{a}} hr{} ul{li{b}}}"},
+		{s: []string{"- ***"}, want: "r{ul{li{hr{}}}}"},
+		{s: []string{"* a", "  ***", "* b"}, want: "r{ul{li{a hr{}} li{b}}}"},
+		{s: []string{"> aa", "> ***"}, want: "r{blockquote{p{aa} hr{}}}"},
+		{s: []string{"> aa", "> ---"}, want: "r{blockquote{h2:id=\"aa\"{aa}}}"},
+
+		// Line breaks
+		{s: []string{"a  ", "b\\", "c", "d  "}, want: "r{p{a<br/>\n b<br/>\n c d}}"},
+		{s: []string{"a\\\\", "b"}, want: "r{p{a\\ b}}"},
+		{s: []string{"a  ", "", "b"}, want: "r{p{a} p{b}}"},
+		{s: []string{"> a\\", "> b"}, want: "r{blockquote{p{a<br/>\n b}}}"},
+		{s: []string{"* a  ", "  b"}, want: "r{ul{li{a<br/>\n b}}}"},
+
+		// Quoting
+		{s: []string{"> quote"},
+			want: "r{blockquote{p{quote}}}"},
+		{s: []string{"aa", "> quote1", "> quote2", "bb"},
+			want: "r{p{aa} blockquote{p{quote1 quote2 bb}}}"},
+		{s: []string{"aa", "> quote1", "", "> quote2", "bb"},
+			want: "r{p{aa} blockquote{p{quote1}} blockquote{p{quote2 bb}}}"},
+		{s: []string{"> a", "> > b", "> > c", "d"},
+			want: "r{blockquote{p{a} blockquote{p{b c d}}}}"},
+		{s: []string{">>> a", "", "b"}, want: "r{blockquote{blockquote{blockquote{p{a}}}} p{b}}"},
+		{s: []string{"> # h", "> - x", "> - y", "- z"},
+			want: "r{blockquote{h1:id=\"h\"{h} ul{li{x} li{y}}} ul{li{z}}}"},
+		{s: []string{">     code"}, want: "r{blockquote{pre{code{code}}}}"},
+		{s: []string{"> ```", "> a", "b"}, want: "r{blockquote{pre{code{a}}} p{b}}"},
+		{s: []string{"> a", "---"}, want: "r{blockquote{p{a}} hr{} p{}}"},
+		{s: []string{"- > a", "  b", "c"}, want: "r{ul{li{blockquote{p{a b c}}}}}"},
+		{s: []string{"> a", ">  ", "> b"}, want: "r{blockquote{p{a} p{b}}}"},
+		{s: []string{"> a", "==="}, want: "r{blockquote{p{a}} p{===}}"},
+		{s: []string{"- a", "==="}, want: "r{ul{li{a}} p{===}}"},
+		{s: []string{"- a", "  ==="}, want: "r{ul{li{h1:id=\"a\"{a}}}}"},
+		{s: []string{">  "}, want: "r{blockquote{}}"},
+		{s: []string{"x", "  ", "y"}, want: "r{p{x} p{y}}"},
+		{s: []string{"- a", "\t", "  b"}, want: "r{ul{li{p{a} p{b}}}}"},
+		{s: []string{"aa`cc`bb"}, want: "r{p{aa<code>cc</code>bb}}"},
+		{s: []string{"aa", "```", "a1", "a2", "```", "bb"}, want: "r{p{aa} pre{code{a1 a2}} p{bb}}"},
+
+		// Fenced code
+		{s: []string{"```go", "x := 1", "```"},
+			want: "r{pre{code:class=\"language-go\"{x := 1}} p{}}"},
+		{s: []string{"``` go  more info", "a", "```"},
+			want: "r{pre{code:class=\"language-go\"{a}} p{}}"},
+		{s: []string{"~~~", "a<b", "~~~"}, want: "r{pre{code{a&lt;b}} p{}}"},
+		{s: []string{"````", "```", "````"}, want: "r{pre{code{```}} p{}}"},
+		{s: []string{"```", "a", "~~~", "``", "```"}, want: "r{pre{code{a ~~~ ``}} p{}}"},
+		{s: []string{"~~~a`b", "a", "~~~~~"},
+			want: "r{pre{code:class=\"language-a`b\"{a}} p{}}"},
+		{s: []string{"```a`b"}, want: "r{p{```a`b}}"},
+		{s: []string{"  ```", "  a", "   b", " c", "d", "  ```"},
+			want: "r{pre{code{a  b c d}} p{}}"},
+		{s: []string{"```", "a", "    ```", "```"}, want: "r{pre{code{a     ```}} p{}}"},
+		{s: []string{"aa", "", "    a<b", "    c"}, want: "r{p{aa} pre{code{a&lt;b c}}}"},
+
+		// Lists
+		{s: []string{"aa", "* 1", "* 2", "  + 2.1", "  + 2.2", "    - 2.2.1", "  + 2.3", "* 3", "cc"},
+			want: "r{p{aa} ul{li{1} li{2 ul{li{2.1} li{2.2 ul{li{2.2.1}}} li{2.3}}} li{3 cc}}}"},
+		{s: []string{"aa", "  * 1", "   l1", "  * 2", "   l2", "c"},
+			want: "r{p{aa} ul{li{1 l1} li{2 l2 c}}}"},
+		{s: []string{"a", "", "- b", "", "  * c", "", "  d", "", "- e", "", "f"},
+			want: "r{p{a} ul{li{p{b} ul{li{c}} p{d}} li{p{e}}} p{f}}"},
+		{s: []string{"* 1", "* 2", "a", "* p", "* q"},
+			want: "r{ul{li{1} li{2 a} li{p} li{q}}}"},
+		{s: []string{"a", "* 1", "* 2", "  + a", "* 3", "", "  b", " c", "* 4", "d"},
+			want: "r{p{a} ul{li{p{1}} li{p{2} ul{li{a}}} li{p{3} p{b c}} li{p{4 d}}}}"},
+		{s: []string{"- a", "- b", "", "c"}, want: "r{ul{li{a} li{b}} p{c}}"},
+		{s: []string{"- a", "", "- b", "- c"}, want: "r{ul{li{p{a}} li{p{b}} li{p{c}}}}"},
+		{s: []string{"- a", "  b", "", "  c"}, want: "r{ul{li{p{a b} p{c}}}}"},
+		{s: []string{"- a", "  - b", "", "  - c"}, want: "r{ul{li{a ul{li{p{b}} li{p{c}}}}}}"},
+		{s: []string{"aa", "1. 1", "2. 2", "   2. 2.1", "   2. 2.2", "cc"},
+			want: "r{p{aa} ol{li{1} li{2 2. 2.1 2. 2.2 cc}}}"},
+		{s: []string{"aa", "1. 1", "2. 2", "   1. 2.1", "   2. 2.2", "", "cc"},
+			want: "r{p{aa} ol{li{1} li{2 ol{li{2.1} li{2.2}}}} p{cc}}"},
+		{s: []string{"1. 1", "2. 2", "   2. 2.1"}, want: "r{ol{li{1} li{2 2. 2.1}}}"},
+		{s: []string{"- a", "", "  ```go", "  x := 1", "  ```", "- b"},
+			want: "r{ul{li{p{a} pre{code:class=\"language-go\"{x := 1}}} li{p{b}}}}"},
+		{s: []string{"1. a", "", "       code", "2. b"},
+			want: "r{ol{li{p{a} pre{code{code}}} li{p{b}}}}"},
+		{s: []string{"- ## a", "  ***", "- b"}, want: "r{ul{li{h2:id=\"a\"{a} hr{}} li{b}}}"},
+		{s: []string{"- a", "  b", "c"}, want: "r{ul{li{a b c}}}"},
+		{s: []string{"- a", "", "      code", "", "", "      more", "", "- b"},
+			want: "r{ul{li{p{a} pre{code{code \n \n more}}} li{p{b}}}}"},
+		{s: []string{"    a", "", "    b", "", "c"}, want: "r{pre{code{a \n b}} p{c}}"},
+		{s: []string{"    a", "", "- b", "", "      c"}, want: "r{pre{code{a}} ul{li{p{b} pre{code{c}}}}}"},
+		{s: []string{"    a", "```", "b", "```"}, want: "r{pre{code{a}} pre{code{b}} p{}}"},
+		{s: []string{"a", "b", ": c", ":   d", "    e", "f"},
+			want: "r{dl{dt{a} dt{b} dd{c} dd{d e f}}}"},
+		{s: []string{"a", "", ": b", "", "    c", "", "d", ": e"},
+			want: "r{dl{dt{a} dd{p{b} p{c}} dt{d} dd{e}}}"},
+		{s: []string{"a", ": - b", "  - c", "", "d"},
+			want: "r{dl{dt{a} dd{ul{li{b} li{c}}}} p{d}}"},
+		{s: []string{": a"}, want: "r{p{: a}}"},
+		{s: []string{"# a", ": b"}, want: "r{h1:id=\"a\"{a} p{: b}}"},
+		{s: []string{"> [!TIP]", "> a", "b"},
+			want: "r{div:class=\"admonition tip\"{p:class=\"admonition-title\"{Tip} p{a b}}}"},
+		{s: []string{"> [!TIP] a"}, want: "r{blockquote{p{[!TIP] a}}}"},
+		{s: []string{"a", ":::note *N*", "b", "", "```", ":::", "```", ":::", "c"},
+			want: "r{p{a} div:class=\"admonition note\"{p:class=\"admonition-title\"{<em>N</em>} " +
+				"p{b} pre{code{:::}}} p{c}}"},
+		{s: []string{"::::x", ":::y", "a", ":::", "::::"},
+			want: "r{div:class=\"admonition x\"{p:class=\"admonition-title\"{X} " +
+				"div:class=\"admonition y\"{p:class=\"admonition-title\"{Y} p{a}}} p{}}"},
+		{s: []string{"a", "$$", "x", "$$", "b"},
+			want: "r{p{a} #html{" + mathml.Convert("x", true) + "\n} p{b}}"},
+		{s: []string{"$$ x", "y $$"}, want: "r{#html{" + mathml.Convert("x\ny", true) + "\n} p{}}"},
+		{s: []string{"a $b_1$ *c*"}, want: "r{p{a " + mathml.Convert("b_1", false) + " <em>c</em>}}"},
+		{s: []string{"- [ ] a", "- [x] b", "- [ ]"},
+			want: "r{ul{li:class=\"task-list-item\"{" + cTaskBox + " a} " +
+				"li:class=\"task-list-item\"{" + cTaskBoxChecked + " b} li{[ ]}}}"},
+		{s: []string{"- \\[x\\] a", "- [x] [a](/u)"},
+			want: "r{ul{li{[x] a} li:class=\"task-list-item\"{" + cTaskBoxChecked +
+				" <a href=\"/u\">a</a>}}}"},
+		{s: []string{"- [ ] a", "", "  b"},
+			want: "r{ul{li:class=\"task-list-item\"{p{" + cTaskBox + " a} p{b}}}}"},
+		{s: []string{"aa", "1. 1", "2. 2", "   - 2.1", "   - 2.2", "cc"},
+			want: "r{p{aa} ol{li{1} li{2 ul{li{2.1} li{2.2 cc}}}}}"},
+		{s: []string{"7. a", "8. b"}, want: "r{ol:start=\"7\"{li{a} li{b}}}"},
+		{s: []string{"1) a", "2) b", "3. c"}, want: "r{ol{li{a} li{b}} ol:start=\"3\"{li{c}}}"},
+		{s: []string{"- a", "- b", "+ c"}, want: "r{ul{li{a} li{b}} ul{li{c}}}"},
+		{s: []string{"- a", "  1. b", "  2) c", "- d"},
+			want: "r{ul{li{a ol{li{b}} ol:start=\"2\"{li{c}}} li{d}}}"},
+		{s: []string{"a", "2. b"}, want: "r{p{a 2. b}}"},
+		{s: []string{"a", "1. b"}, want: "r{p{a} ol{li{b}}}"},
+		{s: []string{"123456789. a"}, want: "r{ol:start=\"123456789\"{li{a}}}"},
+		{s: []string{"1234567890. a"}, want: "r{p{1234567890. a}}"},
+		{s: []string{"1.5 a"}, want: "r{p{1.5 a}}"},
+
+		// Tables
+		{s: []string{"s", "| A | B |", "| --- | --- |", "| a | b |", "", "e"},
+			want: "r{p{s table:style=\"width: 100%\"{tr{th{A} th{B}} tr{td{a} td{b}}} e}}"},
+		{s: []string{"s", "| A \\| B | `C\\|D` |", "| --- | --- |", "| *a* | \\*b\\* |", "", "e"},
+			want: "r{p{s table:style=\"width: 100%\"{tr{th{A | B} th{<code>C|D</code>}} tr{td{<em>a</em>} td{*b*}}} e}}"},
+		{s: []string{"s", "| A | B |", "|| --- |  | --- ||", "| a | b |", "", "e"},
+			want: "r{p{s table:style=\"width: 100%\"{tr{th{A} th{B}} tr{td{a} td{b}}} e}}"},
+		{s: []string{"s", "| A | B | C | D |", "| --- | :--- | ---: | :---: |", "| a | b | c | d |", "e"},
+			want: "r{p{s table:style=\"width: 100%\"{tr{th{A} th:style=\"text-align: left\"{B} th:style=\"text-align: right\"{C} th:style=\"text-align: center\"{D}} tr{td{a} td:style=\"text-align: left\"{b} td:style=\"text-align: right\"{c} td:style=\"text-align: center\"{d}}} e}}"},
+	}
+	for _, tst := range tests {
+		ht := NewHTMLTree("r")
+		ht.br, _ = ht.br.AddBranch(-1, "p")
+
+		for _, s := range tst.s {
+			err := ht.Build(s)
+			if err != nil {
+				t.Fatalf("Build(%q) returns error: %s, should be nil", s, err)
+			}
+		}
+		ht.Finish()
+
+		got := ht.root.String()
+		if got != tst.want {
+			t.Errorf("Build(%q)... generates:\n%q\nshould be:\n%q\n",
+				tst.s[0], got, tst.want)
+		}
+	}
+}
+
+func TestEscapeHTML(t *testing.T) {
+	tests := []struct {
+		s    string
+		safe bool
+		want string
+	}{
+		{s: "a < b && c > d", want: "a &lt; b &amp;&amp; c &gt; d"},
+		{s: "say \"hi\"", want: "say &#34;hi&#34;"},
+		{s: "&amp; &#123;", want: "&amp;amp; &amp;#123;"},
+		{s: "a <b>", want: "a &lt;b&gt;"},
+	}
+
+	for _, tst := range tests {
+		if got := EscapeHTML(tst.s); got != tst.want {
+			t.Errorf("EscapeHTML(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestRawHTML(t *testing.T) {
+	tests := []struct {
+		s    string
+		safe bool
+		want string
+	}{
+		{s: "a <b>bold</b> <br/>", want: "a <b>bold</b> <br/>"},
+		{s: "<span title='a_b'>", want: "<span title='a_b'>"},
+		{s: "a < b", want: "a < b"},
+		{s: "x<!-- note -->y", want: "x<!-- note -->y"},
+		{s: "x<!-- note -->y", safe: true, want: "xy"},
+		{s: "<script>alert(1)</script>", safe: true,
+			want: "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{s: "<b onclick=\"x()\">b</b>", safe: true, want: "<b>b</b>"},
+		{s: "<a href=\"javascript:x()\" title=a>", safe: true,
+			want: "<a title=\"a\">"},
+		{s: "<img src=\"i.png\" onerror=x>", safe: true,
+			want: "<img src=\"i.png\">"},
+		{s: "a <![CDATA[x<y]]> <?php b ?>", want: "a <![CDATA[x<y]]> <?php b ?>"},
+		{s: "a <![CDATA[x<y]]> <?php b ?>", safe: true,
+			want: "a &lt;![CDATA[x&lt;y]]&gt; &lt;?php b ?&gt;"},
+		{s: "<!DOCTYPE html>", want: "<!DOCTYPE html>"},
+	}
+
+	for _, tst := range tests {
+		var sn *Sanitizer
+		if tst.safe {
+			sn = NewSanitizer(nil)
+		}
+		in := Inliner{Sn: sn}
+		if got := in.unstash(in.RawHTML(tst.s)); got != tst.want {
+			t.Errorf("RawHTML(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestHTMLBlockStart(t *testing.T) {
+	tests := []struct {
+		s      string
+		inPara bool
+		want   int
+	}{
+		{s: "<pre>", want: 1},
+		{s: "<SCRIPT type=\"x\">", want: 1},
+		{s: "<preview>", want: 7},
+		{s: "<!-- note", want: 2},
+		{s: "<?php", want: 3},
+		{s: "<!DOCTYPE html>", want: 4},
+		{s: "<![CDATA[", want: 5},
+		{s: "<details>", want: 6},
+		{s: "</div>", inPara: true, want: 6},
+		{s: "<video src=\"a.mp4\" controls>", want: 7},
+		{s: "<video src=\"a.mp4\" controls>", inPara: true, want: 0},
+		{s: "<b>bold</b>", want: 0},
+		{s: "a <div>", want: 0},
+	}
+
+	for _, tst := range tests {
+		if got := HTMLBlockStart(tst.s, tst.inPara); got != tst.want {
+			t.Errorf("HTMLBlockStart(%q) generates:\n%d\nshould be:\n%d\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestParseAllow(t *testing.T) {
+	sn := NewSanitizer(ParseAllow("b, a:href:title"))
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "<b>", want: "<b>"},
+		{s: "<i>", want: "&lt;i&gt;"},
+		{s: "<a href=\"x\" title=\"t\" class=\"c\">", want: "<a href=\"x\" title=\"t\">"},
+	}
+
+	for _, tst := range tests {
+		if got := sn.Tag(tst.s); got != tst.want {
+			t.Errorf("Tag(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	tests := []struct {
+		s    string
+		safe bool
+		want string
+	}{
+		{s: "aa ![im](lnk) bb",
+			want: "aa <img src=\"lnk\" alt=\"im\"/> bb"},
+		{s: "![i1](l1)![i2](l2)",
+			want: "<img src=\"l1\" alt=\"i1\"/><img src=\"l2\" alt=\"i2\"/>"},
+		{s: "aa ![im](data:image/png;base64,AAAA) bb", safe: true,
+			want: "aa im bb"},
+		{s: "![a `b` \"c\"](<i m.png> 'T')",
+			want: "<img src=\"i%20m.png\" alt=\"a `b` &#34;c&#34;\" title=\"T\"/>"},
+	}
+
+	for _, tst := range tests {
+		var sn *Sanitizer
+		if tst.safe {
+			sn = NewSanitizer(nil)
+		}
+		in := Inliner{Sn: sn}
+		got := in.unstash(in.Images(tst.s))
+		if got != tst.want {
+			t.Errorf("Images(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestInline(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "a<b & `a<b` <i>x</i>", want: "a&lt;b &amp; <code>a&lt;b</code> <i>x</i>"},
+		{s: "x *[a_b](u_v)* `_c_`",
+			want: "x <em><a href=\"u_v\">a_b</a></em> <code>_c_</code>"},
+		{s: "![a `b` <i>c</i>](i.png)", want: "<img src=\"i.png\" alt=\"a b c\"/>"},
+		{s: "<span title=\"a_b_\">x</span>", want: "<span title=\"a_b_\">x</span>"},
+		{s: "a\x00b", want: "a\uFFFDb"},
+		{s: "&copy; &#60;b&#x3e; &foo; AT&T &#42;a&#42; \\&amp; `&amp;`",
+			want: "© &lt;b&gt; &amp;foo; AT&amp;T *a* &amp;amp; <code>&amp;amp;</code>"},
+		{s: "[a](/f&ouml;&ouml; \"f&ouml;&ouml;\")",
+			want: "<a href=\"/föö\" title=\"föö\">a</a>"},
+		{s: "[a](javascript&#58;x)", want: "<a href=\"javascript:x\">a</a>"},
+		{s: "**foo \\*bar\\***", want: "<strong>foo *bar*</strong>"},
+		{s: "\\`not code` \\<b> \\[a](b) \\\\*c*", want: "`not code` &lt;b&gt; [a](b) \\<em>c</em>"},
+		{s: "`\\*` U+002A \\&amp; \\# \\x", want: "<code>\\*</code> U+002A &amp;amp; # \\x"},
+		{s: "[a\\]b](c\\)d \"t\\\"\")", want: "<a href=\"c)d\" title=\"t&#34;\">a]b</a>"},
+		{s: "[a](javascript\\:alert(1))", want: "<a href=\"javascript:alert(1)\">a</a>"},
+		{s: "*[a*](b) **[*c*](d)**",
+			want: "*<a href=\"b\">a*</a> <strong><a href=\"d\"><em>c</em></a></strong>"},
+	}
+
+	for _, tst := range tests {
+		in := Inliner{}
+		if got := in.Inline(tst.s); got != tst.want {
+			t.Errorf("Inline(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestInlineCodes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "aa `code` bb", want: "aa <code>code</code> bb"},
+		{s: "a `a<b` `*c*`", want: "a <code>a&lt;b</code> <code>*c*</code>"},
+	}
+
+	for _, tst := range tests {
+		in := Inliner{}
+		got := in.unstash(in.InlineCodes(tst.s))
+		if got != tst.want {
+			t.Errorf("InlineCodes(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestMath(t *testing.T) {
+	x := mathml.Convert("x", false)
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "a $x$ b", want: "a " + x + " b"},
+		{s: "a $$x$$ b", want: "a " + mathml.Convert("x", true) + " b"},
+		{s: "$a_1$ $b_2$",
+			want: mathml.Convert("a_1", false) + " " + mathml.Convert("b_2", false)},
+		{s: "costs $5 and $10", want: "costs $5 and $10"},
+		{s: "$ x$ $x $ $x$1", want: "$ x$ $x $ $x$1"},
+		{s: "\\$x$", want: "\\$x$"},
+		{s: "text $$", want: "text $$"},
+		{s: "costs 5$$", want: "costs 5$$"},
+		{s: "$", want: "$"},
+		{s: "$$ $x$", want: "$$ " + x},
+		{s: "a $$$x$", want: "a $$" + x},
+		{s: "\\$$x$", want: "\\$" + x},
+	}
+
+	for _, tst := range tests {
+		in := Inliner{}
+		got := in.unstash(in.Math(tst.s))
+		if got != tst.want {
+			t.Errorf("Math(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		s    string
+		safe bool
+		want string
+	}{
+		{s: "aa [txt](link) bb", want: "aa <a href=\"link\">txt</a> bb"},
+		{s: "[t1](l1)[t2](l2)", want: "<a href=\"l1\">t1</a><a href=\"l2\">t2</a>"},
+		{s: "aa [txt](javascript:alert(1)) bb",
+			want: "aa <a href=\"javascript:alert(1)\">txt</a> bb"},
+		{s: "aa [txt](javascript:alert(1)) bb", safe: true, want: "aa txt bb"},
+		{s: "[Go](https://en.wikipedia.org/wiki/Go_(language))",
+			want: "<a href=\"https://en.wikipedia.org/wiki/Go_(language)\">Go</a>"},
+		{s: "[x](url \"Title\")", want: "<a href=\"url\" title=\"Title\">x</a>"},
+		{s: "[x](url 'a \"b\"')", want: "<a href=\"url\" title=\"a &#34;b&#34;\">x</a>"},
+		{s: "[x]( url (T) )", want: "<a href=\"url\" title=\"T\">x</a>"},
+		{s: "[x](<my url> \"T\")", want: "<a href=\"my%20url\" title=\"T\">x</a>"},
+		{s: "[x](<a>b>)", want: "[x](<a>b>)"},
+		{s: "[x](a(b)", want: "[x](a(b)"},
+		{s: "[x](url \"T)", want: "[x](url \"T)"},
+		{s: "[x](url\"T\")", want: "<a href=\"url&#34;T&#34;\">x</a>"},
+		{s: "[txt](JaVa&#83;cript:x)", safe: true, want: "txt"},
+		{s: "[txt](http://x.nl/a:b)", safe: true,
+			want: "<a href=\"http://x.nl/a:b\">txt</a>"},
+		{s: "[txt][Ref 1] and [txt][nope]",
+			want: "<a href=\"/u1\" title=\"T 1\">txt</a> and [txt][nope]"},
+		{s: "[ref  1][] [REF 1]",
+			want: "<a href=\"/u1\" title=\"T 1\">ref  1</a> <a href=\"/u1\" title=\"T 1\">REF 1</a>"},
+		{s: "[a] [b](l)", want: "[a] <a href=\"l\">b</a>"},
+		{s: "[x&y]", want: "<a href=\"/a?b=1&amp;c=2\">x&amp;y</a>"},
+	}
+	refs := Refs{
+		"ref 1": Ref{URL: "/u1", Title: "T 1"},
+		"x&y":   Ref{URL: "/a?b=1&c=2"},
+	}
+
+	for _, tst := range tests {
+		var sn *Sanitizer
+		if tst.safe {
+			sn = NewSanitizer(nil)
+		}
+		in := Inliner{Refs: refs, Sn: sn}
+		got := in.unstash(in.Links(tst.s))
+		if got != tst.want {
+			t.Errorf("Links(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestDestLen(t *testing.T) {
+	tests := []struct {
+		s    string
+		i    int
+		want int
+	}{
+		{s: "[x](<a b>)", i: 4, want: 5},
+		{s: "[x]( <a b> \"t\" )", i: 5, want: 10},
+		{s: "[x](<a b>", i: 4, want: 0},
+		{s: "x <a b>)", i: 2, want: 0},
+		{s: "[x](<a>b>)", i: 4, want: 0},
+	}
+
+	for _, tst := range tests {
+		if got := DestLen(tst.s, tst.i); got != tst.want {
+			t.Errorf("DestLen(%q, %d) returns %d, should be %d", tst.s, tst.i, got, tst.want)
+		}
+	}
+}
+
+func TestAutolinks(t *testing.T) {
+	tests := []struct {
+		s    string
+		safe bool
+		want string
+	}{
+		{s: "see <https://x.nl/a?b=1&c=2>.",
+			want: "see <a href=\"https://x.nl/a?b=1&amp;c=2\">https://x.nl/a?b=1&amp;c=2</a>."},
+		{s: "<Frank.S+md@foef.nl>",
+			want: "<a href=\"mailto:Frank.S+md@foef.nl\">Frank.S+md@foef.nl</a>"},
+		{s: "<x.nl> <a b:c> <m:a>", want: "<x.nl> <a b:c> <m:a>"},
+		{s: "<javascript:alert(1)>", safe: true, want: "javascript:alert(1)"},
+	}
+
+	for _, tst := range tests {
+		var sn *Sanitizer
+		if tst.safe {
+			sn = NewSanitizer(nil)
+		}
+		in := Inliner{Sn: sn}
+		if got := in.unstash(in.Autolinks(tst.s)); got != tst.want {
+			t.Errorf("Autolinks(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestBareURLLen(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "www.commonmark.org/help", want: "www.commonmark.org/help"},
+		{s: "www.commonmark.org.", want: "www.commonmark.org"},
+		{s: "www.commonmark.org/a.b.", want: "www.commonmark.org/a.b"},
+		{s: "https://x.nl/a?b!,", want: "https://x.nl/a?b"},
+		{s: "www.google.com/search?q=(business))+ok)", want: "www.google.com/search?q=(business))+ok"},
+		{s: "www.google.com/search?q=Markup+(business)))", want: "www.google.com/search?q=Markup+(business)"},
+		{s: "www.google.com/search?q=commonmark&hl;", want: "www.google.com/search?q=commonmark"},
+		{s: "www.google.com/search?q=commonmark&hl=en", want: "www.google.com/search?q=commonmark&hl=en"},
+		{s: "www.x.nl<b>", want: "www.x.nl"},
+		{s: "http://a_b.x_y.nl", want: ""},
+		{s: "www.", want: ""},
+		{s: "ftp://x.nl", want: ""},
+	}
+
+	for _, tst := range tests {
+		if got := tst.s[:BareURLLen(tst.s)]; got != tst.want {
+			t.Errorf("BareURLLen(%q) gives %q, should be %q", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestBareURLs(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "see www.x.nl, or (https://y.nl/a).",
+			want: "see <a href=\"http://www.x.nl\">www.x.nl</a>, or (<a href=\"https://y.nl/a\">https://y.nl/a</a>)."},
+		{s: "xwww.x.nl", want: "xwww.x.nl"},
+	}
+
+	for _, tst := range tests {
+		in := Inliner{LinkURLs: true}
+		if got := in.unstash(in.BareURLs(tst.s)); got != tst.want {
+			t.Errorf("BareURLs(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestCollectRefs(t *testing.T) {
+	tests := []struct {
+		s     string
+		refs  Refs
+		lines int
+	}{
+		{s: "[a]: /u\n[B]:  /v  \"T\"\n", lines: 1,
+			refs: Refs{"a": Ref{URL: "/u"}, "b": Ref{URL: "/v", Title: "T"}}},
+		{s: "[a]: /u\n[a]: /v\n", lines: 1, refs: Refs{"a": Ref{URL: "/u"}}},
+		{s: "text\n[a]: /u\n", lines: 3, refs: Refs{}},
+		{s: "# hdr\n[a]: /u 'T'\n", lines: 2,
+			refs: Refs{"a": Ref{URL: "/u", Title: "T"}}},
+		{s: "```\n[a]: /u\n```\n", lines: 4, refs: Refs{}},
+		{s: "    [a]: /u\n", lines: 2, refs: Refs{}},
+		{s: "[a]: /u x\n", lines: 2, refs: Refs{}},
+		{s: "[a]: <my url> (T)\n", lines: 1,
+			refs: Refs{"a": Ref{URL: "my url", Title: "T"}}},
+		{s: "[&auml;]: /f&ouml;o \"\\\"T&quot;\"\n", lines: 1,
+			refs: Refs{"ä": Ref{URL: "/föo", Title: "\"T\""}}},
+		{s: "[a]: /u\n  \"T\"\n[b]: /v\n'x' y\n", lines: 2,
+			refs: Refs{"a": Ref{URL: "/u", Title: "T"}, "b": Ref{URL: "/v"}}},
+		{s: "[a]: /u\n[a]: /v\n(T)\n", lines: 1, refs: Refs{"a": Ref{URL: "/u"}}},
+		{s: "> [a]: /u\n> > [b]: /v\n> \"T\"\n", lines: 4,
+			refs: Refs{"a": Ref{URL: "/u"}, "b": Ref{URL: "/v"}}},
+		{s: "a\n> [a]: /u\n> b\n", lines: 4, refs: Refs{"a": Ref{URL: "/u"}}},
+		{s: "> a\n[a]: /u\n", lines: 3, refs: Refs{}},
+		{s: "> ```\n> [a]: /u\n[b]: /v\n", lines: 3, refs: Refs{"b": Ref{URL: "/v"}}},
+	}
+
+	for _, tst := range tests {
+		refs, lines := CollectRefs(strings.SplitAfter(tst.s, "\n"))
+		if !reflect.DeepEqual(refs, tst.refs) || len(lines) != tst.lines {
+			t.Errorf("CollectRefs(%q) returns %v and %d lines, should be %v and %d",
+				tst.s, refs, len(lines), tst.refs, tst.lines)
+		}
+	}
+}
+
+func TestQuotePrefix(t *testing.T) {
+	tests := []struct {
+		s      string
+		max    int
+		prefix string
+	}{
+		{s: "> a", max: -1, prefix: "> "},
+		{s: " >> > a", max: -1, prefix: " >> > "},
+		{s: "> > a", max: 1, prefix: "> "},
+		{s: "    > a", max: -1, prefix: ""},
+		{s: "a > b", max: -1, prefix: ""},
+	}
+
+	for _, tst := range tests {
+		if prefix, rest := QuotePrefix(tst.s, tst.max); prefix != tst.prefix || prefix+rest != tst.s {
+			t.Errorf("QuotePrefix(%q, %d) returns %q, %q, should be %q and the rest",
+				tst.s, tst.max, prefix, rest, tst.prefix)
+		}
+	}
+}
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		s     string
+		title string
+		ok    bool
+	}{
+		{s: " \"a b\" ", title: "a b", ok: true},
+		{s: "'a\\'b'", title: "a'b", ok: true},
+		{s: "(a)", title: "a", ok: true},
+		{s: "\"a\" b", ok: false},
+		{s: "\"a", ok: false},
+		{s: "a", ok: false},
+	}
+
+	for _, tst := range tests {
+		if title, ok := ParseTitle(tst.s); title != tst.title || ok != tst.ok {
+			t.Errorf("ParseTitle(%q) returns %q, %t, should be %q, %t",
+				tst.s, title, ok, tst.title, tst.ok)
+		}
+	}
+}
+
+func TestCollectNotes(t *testing.T) {
+	tests := []struct {
+		s     string
+		defs  map[string][]string
+		lines int
+	}{
+		{s: "[^a]: x\n[^B]: y\n", lines: 0,
+			defs: map[string][]string{"a": {"x\n"}, "b": {"y\n"}}},
+		{s: "[^a]: x\ny\n\n    z\n\nw\n", lines: 3,
+			defs: map[string][]string{"a": {"x\n", "y\n", "\n", "z\n"}}},
+		{s: "[^a]: x\n- y\n", lines: 2,
+			defs: map[string][]string{"a": {"x\n"}}},
+		{s: "text\n[^a]: x\n", lines: 3, defs: map[string][]string{}},
+		{s: "```\n[^a]: x\n```\n", lines: 4, defs: map[string][]string{}},
+		{s: "[^a b]: x\n", lines: 2, defs: map[string][]string{}},
+		{s: "[a]: /u\n", lines: 2, defs: map[string][]string{}},
+	}
+
+	for _, tst := range tests {
+		notes, lines := CollectNotes(strings.SplitAfter(tst.s, "\n"))
+		defs := map[string][]string{}
+		for label, note := range notes.defs {
+			defs[label] = note.Lines
+		}
+		if !reflect.DeepEqual(defs, tst.defs) || len(lines) != tst.lines {
+			t.Errorf("CollectNotes(%q) returns %v and %d lines, should be %v and %d",
+				tst.s, defs, len(lines), tst.defs, tst.lines)
+		}
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		s     string
+		fm    FrontMatter
+		lines int
+	}{
+		{s: "---\ntitle: My \"page\"\nTags: [a, 'b, c']\n---\n# x\n", lines: 2,
+			fm: FrontMatter{"title": {"My \"page\""}, "tags": {"a", "b, c"}}},
+		{s: "---\n# comment\nauthor: \"F. S.\"\nkeywords:\n  - a\n  - b # x\n...\n", lines: 1,
+			fm: FrontMatter{"author": {"F. S."}, "keywords": {"a", "b"}}},
+		{s: "---\nsite:\n  name: s\n  url: /u\nlang: nl\n---\n", lines: 1,
+			fm: FrontMatter{"site.name": {"s"}, "site.url": {"/u"}, "lang": {"nl"}}},
+		{s: "+++\ntitle = \"T\" # c\nkeywords = [\"a\", \"b\"]\n[params]\nx = 'y'\n+++\n", lines: 1,
+			fm: FrontMatter{"title": {"T"}, "keywords": {"a", "b"}, "params.x": {"y"}}},
+		{s: "---\ndescription: > # c\n  A long\n  text.\n\n  More.\n\nnotes: |-\n  a\n   b\ntitle: T\n---\n",
+			lines: 1, fm: FrontMatter{"description": {"A long text.\nMore.\n"}, "notes": {"a\n b"},
+				"title": {"T"}}},
+		{s: "---\nsite:\n  text: |+\n    a\n\n  name: s\n---\n", lines: 1,
+			fm: FrontMatter{"site.text": {"a\n\n"}, "site.name": {"s"}}},
+		{s: "---\ntitle: T\n", lines: 3},
+		{s: "a\n---\n", lines: 3},
+		{s: " ---\na: b\n---\n", lines: 4},
+		{s: "---\n\n# Intro\n\nSome text.\n\n---\n\nMore text.\n", lines: 10},
+		{s: "---\nfoo\n---\n", lines: 4},
+		{s: "---\n- a\n---\n", lines: 4},
+		{s: "---\n# a\n---\n", lines: 4},
+		{s: "+++\na = 1\nb\n+++\n", lines: 5},
+	}
+
+	for _, tst := range tests {
+		fm, lines := ParseFrontMatter(strings.SplitAfter(tst.s, "\n"))
+		if !reflect.DeepEqual(fm, tst.fm) || len(lines) != tst.lines {
+			t.Errorf("ParseFrontMatter(%q) returns %v and %d lines, should be %v and %d",
+				tst.s, fm, len(lines), tst.fm, tst.lines)
+		}
+	}
+}
+
+func TestFrontMatterOptions(t *testing.T) {
+	fm := FrontMatter{"title": {"F"}, "description": {"D\n"}, "tags": {"a", "b"},
+		"stylesheets": {"s.css"}}
+	sheets := make([]string, 1, 2)
+	sheets[0] = "t.css"
+	got := fm.Options(Options{Title: "T", Stylesheets: sheets})
+	want := Options{Title: "T", Description: "D", Stylesheets: []string{"t.css", "s.css"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Options() for %v returns %+v, should be %+v", fm, got, want)
+	}
+	if sheets = sheets[:2]; sheets[1] != "" {
+		t.Errorf("Options() for %v changes the style sheets of the caller: %v", fm, sheets)
+	}
+}
+
+func TestHeaderText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "## a\n# *b* `c` &amp; [d](/u)\n# e\n", want: "b c & d"},
+		{s: "A  **title**\n===\n", want: "A title"},
+		{s: "> # a\n## b\n", want: ""},
+	}
+
+	for _, tst := range tests {
+		body, err := BuildHTMLTree(strings.NewReader(tst.s), Options{})
+		if err != nil {
+			t.Fatalf("BuildHTMLTree(%q) returns error: %s, should be nil", tst.s, err)
+		}
+		if got := HeaderText(body); got != tst.want {
+			t.Errorf("HeaderText() for %q returns %q, should be %q", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestTaskBox(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "[ ] a", want: cTaskBox},
+		{s: "[x] a", want: cTaskBoxChecked},
+		{s: "[X]\ta", want: cTaskBoxChecked},
+		{s: "[ ]", want: ""},
+		{s: "[ ]  ", want: ""},
+		{s: "[x]a", want: ""},
+		{s: "[-] a", want: ""},
+		{s: "a [ ] b", want: ""},
+	}
+
+	for _, tst := range tests {
+		if got := TaskBox(tst.s); got != tst.want {
+			t.Errorf("TaskBox(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		s    string
+		opts Options
+		want string
+	}{
+		{s: "# hdr\naa\n",
+			opts: Options{Fragment: true},
+			want: "<h1 id=\"hdr\">hdr</h1>\r\n<p>aa</p>\r\n"},
+		{s: "aa\n",
+			opts: Options{Title: "T"},
+			want: "<html>\r\n <head>\r\n  <title>T</title>\r\n" +
+				"  <meta charset=\"utf-8\"/>\n" +
+				"  <meta name=\"generator\" content=\"md2html\"/>\n" +
+				"  <meta http-equiv=\"Content-Style-Type\" content=\"text/css\"/>\n" +
+				" </head>\r\n <body>\r\n  <p>aa</p>\r\n </body>\r\n</html>\r\n"},
+		{s: "---\ntitle: F\nlang: nl\nkeywords: [a, b]\nstylesheets: [s.css]\n---\naa\n",
+			opts: Options{Title: "T", Author: "A"},
+			want: "<html lang=\"nl\">\r\n <head>\r\n  <title>T</title>\r\n" +
+				"  <meta charset=\"utf-8\"/>\n" +
+				"  <meta name=\"generator\" content=\"md2html\"/>\n" +
+				"  <meta name=\"author\" content=\"A\"/>\n" +
+				"  <meta name=\"keywords\" content=\"a, b\"/>\n" +
+				"  <meta http-equiv=\"Content-Style-Type\" content=\"text/css\"/>\n" +
+				"  <link rel=\"stylesheet\" href=\"s.css\" type=\"text/css\"/>\n" +
+				" </head>\r\n <body>\r\n  <p>aa</p>\r\n </body>\r\n</html>\r\n"},
+		{s: "# *a* b\n",
+			opts: Options{AutoTitle: true, FileName: "doc/x.md"},
+			want: "<html>\r\n <head>\r\n  <title>a b</title>\r\n" +
+				"  <meta charset=\"utf-8\"/>\n" +
+				"  <meta name=\"generator\" content=\"md2html\"/>\n" +
+				"  <meta http-equiv=\"Content-Style-Type\" content=\"text/css\"/>\n" +
+				" </head>\r\n <body>\r\n  <h1 id=\"a-b\"><em>a</em> b</h1>\r\n </body>\r\n</html>\r\n"},
+		{s: "## a\n",
+			opts: Options{AutoTitle: true, FileName: "doc/x.md"},
+			want: "<html>\r\n <head>\r\n  <title>x</title>\r\n" +
+				"  <meta charset=\"utf-8\"/>\n" +
+				"  <meta name=\"generator\" content=\"md2html\"/>\n" +
+				"  <meta http-equiv=\"Content-Style-Type\" content=\"text/css\"/>\n" +
+				" </head>\r\n <body>\r\n  <h2 id=\"a\">a</h2>\r\n </body>\r\n</html>\r\n"},
+		{s: "---\n\n# Intro\n\nSome text.\n\n---\n\nMore text.\n",
+			opts: Options{Fragment: true},
+			want: "<hr/>\r\n<h1 id=\"intro\">Intro</h1>\r\n<p>Some text.</p>\r\n<hr/>\r\n<p>More text.</p>\r\n"},
+		{s: "---\nfoo\n---\n",
+			opts: Options{Fragment: true},
+			want: "<hr/>\r\n<h2 id=\"foo\">foo</h2>\r\n"},
+		{s: "+++\ntitle = \"x\"\n+++\naa\n",
+			opts: Options{Fragment: true},
+			want: "<p>aa</p>\r\n"},
+		{s: "- [x] a\n- [ ] b\n",
+			opts: Options{},
+			want: "<html>\r\n <head>\r\n" +
+				"  <meta charset=\"utf-8\"/>\n" +
+				"  <meta name=\"generator\" content=\"md2html\"/>\n" +
+				"  <meta http-equiv=\"Content-Style-Type\" content=\"text/css\"/>\n" +
+				"  <meta name=\"tasks-checked\" content=\"1\"/>\n" +
+				"  <meta name=\"tasks-total\" content=\"2\"/>\n" +
+				" </head>\r\n <body>\r\n  <ul>\r\n" +
+				"   <li class=\"task-list-item\"><input type=\"checkbox\" checked disabled/> a</li>\r\n" +
+				"   <li class=\"task-list-item\"><input type=\"checkbox\" disabled/> b</li>\r\n" +
+				"  </ul>\r\n </body>\r\n</html>\r\n"},
+		{s: "- [x] a\n- [ ] b\n\n[x]: /u\n",
+			opts: Options{Fragment: true},
+			want: "<ul>\r\n" +
+				" <li class=\"task-list-item\"><input type=\"checkbox\" checked disabled/> a</li>\r\n" +
+				" <li class=\"task-list-item\"><input type=\"checkbox\" disabled/> b</li>\r\n" +
+				"</ul>\r\n"},
+		{s: "a <i>b</i> <script>c</script>\n",
+			opts: Options{Fragment: true, Safe: true},
+			want: "<p>a <i>b</i> &lt;script&gt;c&lt;/script&gt;</p>\r\n"},
+		{s: "x\n  \n-   \n>  \ny\n",
+			opts: Options{Fragment: true},
+			want: "<p>x</p>\r\n<ul>\r\n <li></li>\r\n</ul>\r\n<blockquote>\r\n</blockquote>\r\n<p>y</p>\r\n"},
+		{s: "- a\n-\n- b\n",
+			opts: Options{Fragment: true},
+			want: "<ul>\r\n <li>a</li>\r\n <li></li>\r\n <li>b</li>\r\n</ul>\r\n"},
+		{s: "> a<b\n",
+			opts: Options{Fragment: true},
+			want: "<blockquote>\r\n <p>a&lt;b</p>\r\n</blockquote>\r\n"},
+		{s: "# a <b>x</b>\n",
+			opts: Options{Fragment: true},
+			want: "<h1 id=\"a-x\">a <b>x</b></h1>\r\n"},
+		{s: "see [the docs][docs]\n\n[Docs]: https://x.nl/docs\n",
+			opts: Options{Fragment: true},
+			want: "<p>see <a href=\"https://x.nl/docs\">the docs</a></p>\r\n"},
+		{s: "<details>\n<summary>S</summary>\n\n*a*\n\n</details>\n",
+			opts: Options{Fragment: true},
+			want: "<details>\n<summary>S</summary>\n<p><em>a</em></p>\r\n</details>\n"},
+		{s: "<video src=\"a.mp4\">\n*a*\n</video>\nb\n",
+			opts: Options{Fragment: true},
+			want: "<video src=\"a.mp4\">\n*a*\n</video>\nb\n"},
+		{s: "<!-- a\nb -->\nc\n",
+			opts: Options{Fragment: true},
+			want: "<!-- a\nb -->\n<p>c</p>\r\n"},
+		{s: "<!-- a\nb -->\nc\n",
+			opts: Options{Fragment: true, Safe: true},
+			want: "<p>c</p>\r\n"},
+		{s: "<div onclick=\"x()\">\n<script>c</script>\n",
+			opts: Options{Fragment: true, Safe: true},
+			want: "<div>\n&lt;script&gt;c&lt;/script&gt;\n"},
+		{s: "<div>\na <b>b</b>\n",
+			opts: Options{Fragment: true, NoHTML: true},
+			want: "<p>&lt;div&gt; a &lt;b&gt;b&lt;/b&gt;</p>\r\n"},
+		{s: "[a](https://x.nl) https://y.nl <https://z.nl>\n",
+			opts: Options{Fragment: true, Autolink: true},
+			want: "<p><a href=\"https://x.nl\">a</a> <a href=\"https://y.nl\">https://y.nl</a> <a href=\"https://z.nl\">https://z.nl</a></p>\r\n"},
+		{s: "[x](<a title=\" onmouseover=alert(1) x=\">)\n",
+			opts: Options{Fragment: true, Safe: true},
+			want: "<p><a href=\"a%20title=&#34;%20onmouseover=alert(1)%20x=&#34;\">x</a></p>\r\n"},
+		{s: "[x](<a b> \"t\") ![i](<a b.png>)\n",
+			opts: Options{Fragment: true},
+			want: "<p><a href=\"a%20b\" title=\"t\">x</a> <img src=\"a%20b.png\" alt=\"i\"/></p>\r\n"},
+		{s: "[x](<https://a.b>) [y](<c>\n",
+			opts: Options{Fragment: true},
+			want: "<p><a href=\"https://a.b\">x</a> [y](<c></p>\r\n"},
+		{s: "[x](a<b title=\"y onclick=z\">c \"<i title='t'>\")\n",
+			opts: Options{Fragment: true, Safe: true},
+			want: "<p><a href=\"a&lt;b&gt;c\" title=\"&lt;i&gt;\">x</a></p>\r\n"},
+		{s: "[x](/&copy \"&amp\")\n",
+			opts: Options{Fragment: true},
+			want: "<p><a href=\"/&amp;copy\" title=\"&amp;amp\">x</a></p>\r\n"},
+		{s: "a &notit; [x](/&copyx;)\n",
+			opts: Options{Fragment: true},
+			want: "<p>a &amp;notit; <a href=\"/&amp;copyx;\">x</a></p>\r\n"},
+		{s: "[x](/a\\)b&amp;c \"t&amp;\\\"\")\n",
+			opts: Options{Fragment: true},
+			want: "<p><a href=\"/a)b&amp;c\" title=\"t&amp;&#34;\">x</a></p>\r\n"},
+		{s: "[a](javascript\\:x) *b*\n",
+			opts: Options{Fragment: true, Safe: true},
+			want: "<p>a <em>b</em></p>\r\n"},
+		{s: "a[^1] b[^x] c[^1]\n\n[^1]: *n*\n",
+			opts: Options{Fragment: true},
+			want: "<p>a<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup> " +
+				"b[^x] c<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1-2\">1</a></sup></p>\r\n" +
+				"<section class=\"footnotes\">\r\n <ol>\r\n" +
+				"  <li id=\"fn-1\"><p><em>n</em> <a href=\"#fnref-1\" class=\"footnote-backref\">&#8617;</a> " +
+				"<a href=\"#fnref-1-2\" class=\"footnote-backref\">&#8617;<sup>2</sup></a></p></li>\r\n" +
+				" </ol>\r\n</section>\r\n"},
+		{s: "a[^1]\n\n[^1]: x[^2]\n\n        code\n[^2]: y\n[^3]: z\n",
+			opts: Options{Fragment: true},
+			want: "<p>a<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup></p>\r\n" +
+				"<section class=\"footnotes\">\r\n <ol>\r\n" +
+				"  <li id=\"fn-1\"><p>x<sup class=\"footnote-ref\"><a href=\"#fn-2\" id=\"fnref-2\">2</a></sup></p>" +
+				"<pre><code>code\n</code></pre><p><a href=\"#fnref-1\" class=\"footnote-backref\">&#8617;</a></p></li>\r\n" +
+				"  <li id=\"fn-2\"><p>y <a href=\"#fnref-2\" class=\"footnote-backref\">&#8617;</a></p></li>\r\n" +
+				" </ol>\r\n</section>\r\n"},
+		{s: "> x[^a]\n\n[^a]: n\n",
+			opts: Options{Fragment: true},
+			want: "<blockquote>\r\n <p>x<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup></p>\r\n</blockquote>\r\n" +
+				"<section class=\"footnotes\">\r\n <ol>\r\n" +
+				"  <li id=\"fn-1\"><p>n <a href=\"#fnref-1\" class=\"footnote-backref\">&#8617;</a></p></li>\r\n" +
+				" </ol>\r\n</section>\r\n"},
+		{s: "- x[^a]\n\n[^a]: n\n",
+			opts: Options{Fragment: true},
+			want: "<ul>\r\n <li>x<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup></li>\r\n</ul>\r\n" +
+				"<section class=\"footnotes\">\r\n <ol>\r\n" +
+				"  <li id=\"fn-1\"><p>n <a href=\"#fnref-1\" class=\"footnote-backref\">&#8617;</a></p></li>\r\n" +
+				" </ol>\r\n</section>\r\n"},
+		{s: "| A[^a] |\n|---|\n\n[^a]: n\n",
+			opts: Options{Fragment: true},
+			want: "<p>\r\n <table style=\"width: 100%\">\r\n  <tr>\r\n   <th>A<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup></th>\r\n" +
+				"  </tr>\r\n </table>\r\n</p>\r\n" +
+				"<section class=\"footnotes\">\r\n <ol>\r\n" +
+				"  <li id=\"fn-1\"><p>n <a href=\"#fnref-1\" class=\"footnote-backref\">&#8617;</a></p></li>\r\n" +
+				" </ol>\r\n</section>\r\n"},
+		{s: "```\nx[^a]\n```\n    y[^a]\n\nz[^b]\n\n[^a]: m\n[^b]: n\n",
+			opts: Options{Fragment: true},
+			want: "<pre><code>x[^a]\n</code></pre>\r\n<pre><code>y[^a]\n</code></pre>\r\n<p>z<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup></p>\r\n" +
+				"<section class=\"footnotes\">\r\n <ol>\r\n" +
+				"  <li id=\"fn-1\"><p>n <a href=\"#fnref-1\" class=\"footnote-backref\">&#8617;</a></p></li>\r\n" +
+				" </ol>\r\n</section>\r\n"},
+		{s: "> [a]: /u\n> \"T\"\n> b [a]\n",
+			opts: Options{Fragment: true},
+			want: "<blockquote>\r\n <p>b <a href=\"/u\" title=\"T\">a</a></p>\r\n</blockquote>\r\n"},
+		{s: "a  \nb\\\nc\n",
+			opts: Options{Fragment: true},
+			want: "<p>a<br/>\nb<br/>\nc</p>\r\n"},
+		{s: "a\nb\n",
+			opts: Options{Fragment: true, SoftBreak: "newline"},
+			want: "<p>a\nb</p>\r\n"},
+		{s: "a\nb  \nc\n",
+			opts: Options{Fragment: true, SoftBreak: "br"},
+			want: "<p>a<br/>\nb<br/>\nc</p>\r\n"},
+	}
+
+	for _, tst := range tests {
+		var b strings.Builder
+		if err := Convert(strings.NewReader(tst.s), &b, tst.opts); err != nil {
+			t.Fatalf("Convert(%q) returns error: %s, should be nil", tst.s, err)
+		}
+		if got := b.String(); got != tst.want {
+			t.Errorf("Convert(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestConvertFrontMatter(t *testing.T) {
+	var b strings.Builder
+	s := "---\ntitle: T\nsite:\n  name: S\n---\naa\n"
+	fm, err := ConvertFrontMatter(strings.NewReader(s), &b, Options{Fragment: true})
+	if err != nil {
+		t.Fatalf("ConvertFrontMatter(%q) returns error: %s, should be nil", s, err)
+	}
+	want := FrontMatter{"title": {"T"}, "site.name": {"S"}}
+	if !reflect.DeepEqual(fm, want) || b.String() != "<p>aa</p>\r\n" {
+		t.Errorf("ConvertFrontMatter(%q) returns %v and generates %q, should be %v and %q",
+			s, fm, b.String(), want, "<p>aa</p>\r\n")
+	}
+}
+
+func TestConvertSoftBreak(t *testing.T) {
+	var b strings.Builder
+	if err := Convert(strings.NewReader("a\n"), &b, Options{SoftBreak: "tab"}); err == nil {
+		t.Errorf("Convert with soft break mode %q returns nil, should be an error", "tab")
+	}
+}
+
+func TestHighLite(t *testing.T) {
+	tests := []struct {
+		s    []string
+		want string
+	}{
+		{s: []string{"```go\n", "x := \"<\"\n", "```\n"},
+			want: "r{pre{code:class=\"language-go\"{x  span:class=\"tok-operator\"{:=}   span:class=\"tok-string\"{&#34;&lt;&#34;} \n}} p{}}"},
+		{s: []string{"```cobol\n", "a < b\n", "```\n"},
+			want: "r{pre{code:class=\"language-cobol\"{a &lt; b\n}} p{}}"},
+		{s: []string{"```sh\n", "# c\n"},
+			want: "r{pre{code:class=\"language-sh\"{span:class=\"tok-comment\"{# c} \n}}}"},
+	}
+
+	for _, tst := range tests {
+		ht := NewHTMLTree("r")
+		ht.opts.Highlight = true
+		ht.br, _ = ht.br.AddBranch(-1, "p")
+
+		for _, s := range tst.s {
+			if err := ht.Build(s); err != nil {
+				t.Fatalf("Build(%q) returns error: %s, should be nil", s, err)
+			}
+		}
+		ht.Finish()
+
+		if got := ht.root.String(); got != tst.want {
+			t.Errorf("Build(%q)... generates:\n%q\nshould be:\n%q\n",
+				tst.s[0], got, tst.want)
+		}
+	}
+}
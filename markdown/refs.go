@@ -0,0 +1,221 @@
+//
+// refs.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// definitions and functions for link reference definitions.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"html"
+	"strings"
+)
+
+// Ref holds the URL and the title of a link reference definition.
+type Ref struct {
+	URL   string
+	Title string
+}
+
+// Refs holds link reference definitions by their normalised labels.
+type Refs map[string]Ref
+
+// CollectRefs collects the link reference definitions in 'lines'. It returns
+// them and the lines without the definitions. A definition can't be part of a
+// paragraph, fenced code or indented code, but it can be in a block quote. Its
+// title can be on the next line. When a label is defined more than once, the
+// first definition is used.
+func CollectRefs(lines []string) (Refs, []string) {
+	refs := Refs{}
+	r := make([]string, 0, len(lines))
+	fence := ""
+	fenceDepth := 0 // number of block quotes holding the fenced code
+	isDef := false
+	isPara := false
+	depth := 0
+	untitled := "" // label of a definition whose title can follow
+	isNew := false // when true the untitled definition is the one used
+
+	for _, line := range lines {
+		if len(fence) > 0 {
+			prefix, s := QuotePrefix(line, fenceDepth)
+			if strings.Count(prefix, ">") == fenceDepth {
+				if IsClosingFence(s, fence) {
+					fence = ""
+				}
+				r = append(r, line)
+				continue
+			}
+			// the block quote ends, and the fenced code in it
+			fence = ""
+		}
+
+		prefix, s := QuotePrefix(line, -1)
+		if d := strings.Count(prefix, ">"); d != depth {
+			if d > depth {
+				// a block quote interrupts a paragraph
+				isPara = false
+			}
+			depth = d
+			isDef = false
+		}
+		indnt := CountLeading(s, ' ', -1)
+		t := strings.TrimSpace(s)
+
+		if isDef && len(untitled) > 0 && len(t) > 0 {
+			if title, ok := ParseTitle(t); ok {
+				if isNew {
+					refs[untitled] = Ref{URL: refs[untitled].URL, Title: title}
+				}
+				untitled = ""
+				r = appendQuoted(r, prefix, line)
+				continue
+			}
+		}
+		untitled = ""
+
+		switch {
+		case indnt < 4 && IsFence(t):
+			fence, _ = SplitFence(t)
+			fenceDepth = depth
+
+		case indnt < 4 && (isDef || !isPara):
+			if label, ref, ok := ParseRefDef(t); ok {
+				_, found := refs[label]
+				if !found {
+					refs[label] = ref
+				}
+				if len(ref.Title) <= 0 {
+					untitled, isNew = label, !found
+				}
+				isDef = true
+				r = appendQuoted(r, prefix, line)
+				continue
+			}
+		}
+
+		isDef = false
+		isPara = len(t) > 0 && len(fence) <= 0 && CountLeading(t, '#', 6) <= 0 &&
+			!IsThematicBreak(t) && (isPara || indnt < 4)
+		r = append(r, line)
+	}
+
+	return refs, r
+}
+
+// appendQuoted appends what is left of 'line', with the block quote markers
+// 'prefix', after removing a definition from it to 'r'. Only the markers are
+// kept, so the block quote doesn't end.
+func appendQuoted(r []string, prefix, line string) []string {
+	if len(prefix) <= 0 {
+		return r
+	}
+	return append(r, strings.TrimRight(prefix, " ")+line[len(strings.TrimRight(line, "\r\n")):])
+}
+
+// QuotePrefix splits 'line' into at most 'max' block quote markers, each
+// with the space following it, and the rest of the line. When 'max' is
+// negative, all markers are split off.
+func QuotePrefix(line string, max int) (prefix, s string) {
+	i := 0
+	for ; max != 0; max-- {
+		n := CountLeading(line[i:], ' ', -1)
+		if n >= 4 || i+n >= len(line) || line[i+n] != '>' {
+			break
+		}
+		i += n + 1
+		if i < len(line) && line[i] == ' ' {
+			i++
+		}
+	}
+	return line[:i], line[i:]
+}
+
+// Lookup returns the definition for the (escaped) label 'label'. 'ok' is
+// false when it isn't defined.
+func (refs Refs) Lookup(label string) (r Ref, ok bool) {
+	r, ok = refs[NormalizeLabel(html.UnescapeString(label))]
+	return r, ok
+}
+
+// NormalizeLabel returns the label 's' in lower case, with its leading and
+// trailing white space removed and all other white space collapsed into a
+// single space.
+func NormalizeLabel(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// ParseRefDef parses the link reference definition '[label]: url "title"' in
+// 's'. The destination and the optional title are parsed by ParseDest. It
+// returns the normalised label and the definition. When 's' isn't a definition, 'ok' is false.
+func ParseRefDef(s string) (label string, r Ref, ok bool) {
+	s = strings.TrimSpace(s)
+	j := strings.Index(s, "]:")
+	if len(s) < 4 || s[0] != '[' || j < 0 {
+		return "", Ref{}, false
+	}
+	label = NormalizeLabel(Unescape(s[1:j]))
+	if len(label) <= 0 || strings.ContainsAny(label, "[]") {
+		return "", Ref{}, false
+	}
+
+	u, title, n, ok := ParseDest(s[j+2:])
+	if !ok || len(u) <= 0 || j+2+n != len(s) {
+		return "", Ref{}, false
+	}
+	r = Ref{URL: Unescape(u), Title: Unescape(title)}
+
+	return label, r, true
+}
+
+// ParseTitle parses the link title that is all of 's', apart from white
+// space. It is enclosed by double quotes, single quotes or parentheses. When
+// 's' isn't a title, 'ok' is false.
+func ParseTitle(s string) (title string, ok bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return "", false
+	}
+	closing := map[byte]byte{'"': '"', '\'': '\'', '(': ')'}[s[0]]
+	if closing == 0 {
+		return "", false
+	}
+	for j := 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\' && j+1 < len(s):
+			j++
+		case s[j] == closing:
+			if j != len(s)-1 {
+				return "", false
+			}
+			return Unescape(s[1:j]), true
+		}
+	}
+	return "", false
+}
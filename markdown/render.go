@@ -0,0 +1,200 @@
+//
+// render.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// functions for writing an HTML tree as HTML code.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/FrankStorbeck/md2html/branch"
+)
+
+// spaces is used for writing indents without allocating them.
+const spaces = "                                "
+
+// SoftBreaks holds the HTML code for each soft line break mode.
+var SoftBreaks = map[string]string{
+	"space":   " ",
+	"newline": "\n",
+	"br":      "<br/>\n",
+}
+
+// renderer writes the HTML code for a tree to a buffered writer. 'last' holds
+// the last byte written, 'soft' the code for a soft line break.
+type renderer struct {
+	w    *bufio.Writer
+	last byte
+	soft string
+}
+
+// newRenderer returns a renderer writing to 'w' which writes 'soft' for soft
+// line breaks. When 'soft' is empty, they become a space.
+func newRenderer(w io.Writer, soft string) renderer {
+	if len(soft) <= 0 {
+		soft = " "
+	}
+	return renderer{w: bufio.NewWriter(w), soft: soft}
+}
+
+// Render writes the HTML code for the tree starting at 'br' to 'w'. Soft line
+// breaks become 'soft'. When an error occured, it will be returned.
+func Render(w io.Writer, br *branch.Branch, soft string) error {
+	r := newRenderer(w, soft)
+	r.branch(br, 0)
+	return r.w.Flush()
+}
+
+// RenderSiblings writes the HTML code for all siblings of 'br' to 'w', but not
+// the tags for 'br' itself. Soft line breaks become 'soft'. When an error
+// occured, it will be returned.
+func RenderSiblings(w io.Writer, br *branch.Branch, soft string) error {
+	r := newRenderer(w, soft)
+	r.siblings(br.Siblings(), 0)
+	return r.w.Flush()
+}
+
+// branch writes the HTML code for 'br' with indent level 'lvl'. A negative
+// level suppresses indents and trailing line ends.
+func (r *renderer) branch(br *branch.Branch, lvl int) {
+	if br.Len() <= 0 && br.ID != cBlockQuote && br.ID != cLi {
+		// an empty block quote or list item still counts
+		return
+	}
+
+	if br.ID == cRawHTML {
+		r.siblings(br.Siblings(), -1)
+		return
+	}
+
+	if br.ID == cTable && lvl >= 0 {
+		r.write(cCrLf)
+	}
+	r.indent(lvl)
+	r.write("<")
+	r.write(br.ID)
+	if len(br.Info) > 0 {
+		r.write(" ")
+		r.write(strings.TrimSpace(br.Info))
+	}
+
+	switch br.ID {
+	case cLink, cMeta:
+		r.write("/>\n")
+		return
+	case cHr:
+		r.write("/>")
+		if lvl >= 0 {
+			r.write(cCrLf)
+		}
+		return
+	}
+
+	r.write(">")
+	if lvl >= 0 {
+		switch br.ID {
+		case cBlockQuote, cBody, cDiv, cDl, cHead, cHTML, cOl, cSection, cTable, cTr, cUl:
+			r.write(cCrLf)
+		}
+	}
+
+	l := lvl
+	switch {
+	case br.ID == cDd, br.ID == cDt, br.ID == cLi, br.ID == cPre:
+		// white space is significant in pre coded text
+		l = -1
+	case l >= 0:
+		l++
+	}
+	r.siblings(br.Siblings(), l)
+
+	switch br.ID {
+	case cBlockQuote, cBody, cDiv, cDl, cHead, cOl, cSection, cTable, cTr, cUl:
+		r.indent(lvl)
+	}
+
+	r.write("</")
+	r.write(br.ID)
+	r.write(">")
+
+	if lvl >= 0 {
+		switch br.ID {
+		case cTable:
+			r.write(cCrLf)
+			r.indent(lvl - 1)
+		case cBody, cBlockQuote, cDd, cDiv, cDl, cDt, cHead, cHTML, cH1, cH2, cH3, cH4, cH5,
+			cH6, cLi, cLink, cOl, cP, cPre, cQ, cSection, cTitle, cScript, cStyle, cTd, cTh, cTr, cUl:
+			r.write(cCrLf)
+		}
+	}
+}
+
+// indent writes the indent for level 'lvl'.
+func (r *renderer) indent(lvl int) {
+	for lvl > 0 {
+		n := lvl
+		if n > len(spaces) {
+			n = len(spaces)
+		}
+		r.write(spaces[:n])
+		lvl -= n
+	}
+}
+
+// siblings writes the HTML code for the siblings 'sbl', where branches get
+// indent level 'lvl'. Consecutive strings are separated by a soft line break,
+// unless the former ends with a line end.
+func (r *renderer) siblings(sbl []interface{}, lvl int) {
+	spc := false
+	for _, c := range sbl {
+		switch k := c.(type) {
+		case *branch.Branch:
+			r.branch(k, lvl)
+			spc = false
+		case string:
+			if spc {
+				r.write(r.soft)
+			}
+			r.write(k)
+			spc = r.last != '\n'
+		}
+	}
+}
+
+// write writes 's' and keeps track of the last byte written.
+func (r *renderer) write(s string) {
+	if len(s) > 0 {
+		r.w.WriteString(s)
+		r.last = s[len(s)-1]
+	}
+}
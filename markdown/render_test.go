@@ -0,0 +1,152 @@
+//
+// render_test.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// tests and benchmarks for render.go.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/FrankStorbeck/md2html/branch"
+)
+
+// report returns mark down text with 'n' sections, each holding a header, a
+// paragraph, a list, a code block and a table.
+func report(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "# Section %d\n\n", i)
+		b.WriteString("Some **bold** and `code` text with a [link](x.html).\n\n")
+		b.WriteString("* item 1\n* item 2\n  - item 2.1\n\n")
+		b.WriteString("```\nfunc f() {\n}\n```\n\n")
+		b.WriteString("| A | B |\n| --- | ---: |\n| a | b |\n\n")
+	}
+	return b.String()
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "# hdr\n", want: "<body>\r\n <h1 id=\"hdr\">hdr</h1>\r\n</body>\r\n"},
+		{s: "aa\nbb\n",
+			want: "<body>\r\n <p>aa bb</p>\r\n</body>\r\n"},
+		{s: "* 1\n* 2",
+			want: "<body>\r\n <ul>\r\n  <li>1</li>\r\n  <li>2</li>\r\n </ul>\r\n</body>\r\n"},
+		{s: "* 1\n\n* 2",
+			want: "<body>\r\n <ul>\r\n  <li><p>1</p></li>\r\n  <li><p>2</p></li>\r\n </ul>\r\n</body>\r\n"},
+		{s: "a\n: b\n",
+			want: "<body>\r\n <dl>\r\n  <dt>a</dt>\r\n  <dd>b</dd>\r\n </dl>\r\n</body>\r\n"},
+		{s: "> quote\n",
+			want: "<body>\r\n <blockquote>\r\n  <p>quote</p>\r\n </blockquote>\r\n</body>\r\n"},
+	}
+
+	for _, tst := range tests {
+		body, err := BuildHTMLTree(strings.NewReader(tst.s), Options{})
+		if err != nil {
+			t.Fatalf("BuildHTMLTree(%q) returns error: %s, should be nil", tst.s, err)
+		}
+		var b strings.Builder
+		if err = Render(&b, body, ""); err != nil {
+			t.Fatalf("Render(%q) returns error: %s, should be nil", tst.s, err)
+		}
+		if got := b.String(); got != tst.want {
+			t.Errorf("Render(%q) generates:\n%q\nshould be:\n%q\n", tst.s, got, tst.want)
+		}
+	}
+}
+
+func TestRenderAllocs(t *testing.T) {
+	for _, n := range []int{10, 1000} {
+		body, err := BuildHTMLTree(strings.NewReader(report(n)), Options{})
+		if err != nil {
+			t.Fatalf("BuildHTMLTree returns error: %s, should be nil", err)
+		}
+		allocs := testing.AllocsPerRun(10, func() {
+			Render(ioutil.Discard, body, "")
+		})
+		// only the renderer and its buffer may be allocated
+		if allocs > 2 {
+			t.Errorf("Render with %d sections does %.0f allocations, should be at most 2",
+				n, allocs)
+		}
+	}
+}
+
+func benchmarkTree(b *testing.B, n int) *branch.Branch {
+	body, err := BuildHTMLTree(strings.NewReader(report(n)), Options{})
+	if err != nil {
+		b.Fatalf("BuildHTMLTree returns error: %s, should be nil", err)
+	}
+	return body
+}
+
+func BenchmarkRender(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		body := benchmarkTree(b, n)
+		b.Run(fmt.Sprintf("sections=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Render(ioutil.Discard, body, "")
+			}
+		})
+	}
+}
+
+func BenchmarkConvert(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		s := report(n)
+		b.Run(fmt.Sprintf("sections=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(s)))
+			for i := 0; i < b.N; i++ {
+				Convert(strings.NewReader(s), ioutil.Discard, Options{})
+			}
+		})
+	}
+}
+
+func BenchmarkAutolink(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		s := strings.Repeat("see www.example.com/a_(b) or http://x.org and more text ", n) + "\n"
+		b.Run(fmt.Sprintf("urls=%d", 2*n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(s)))
+			for i := 0; i < b.N; i++ {
+				Convert(strings.NewReader(s), ioutil.Discard, Options{Autolink: true})
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+//
+// sanitize.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// definitions and functions for recognising and sanitising raw HTML.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"html"
+	"strings"
+)
+
+// DefaultAllow holds the tags, and their attributes, that are allowed in
+// safe mode when no other allow-list is given.
+var DefaultAllow = map[string][]string{
+	"a":       {"href", "title"},
+	"abbr":    {"title"},
+	"b":       nil,
+	"br":      nil,
+	"code":    nil,
+	"dd":      nil,
+	"del":     nil,
+	"details": nil,
+	"div":     nil,
+	"dl":      nil,
+	"dt":      nil,
+	"em":      nil,
+	"hr":      nil,
+	"i":       nil,
+	"img":     {"src", "alt", "title", "width", "height"},
+	"ins":     nil,
+	"kbd":     nil,
+	"mark":    nil,
+	"p":       nil,
+	"q":       {"cite"},
+	"s":       nil,
+	"samp":    nil,
+	"small":   nil,
+	"span":    nil,
+	"strong":  nil,
+	"sub":     nil,
+	"summary": nil,
+	"sup":     nil,
+	"u":       nil,
+	"var":     nil,
+}
+
+// urlAttrs holds the attributes that hold a URL.
+var urlAttrs = map[string]bool{
+	"action": true, "background": true, "cite": true, "formaction": true,
+	"href": true, "poster": true, "src": true,
+}
+
+// Sanitizer sanitises raw HTML and URLs. A nil Sanitizer leaves everything
+// as it is.
+type Sanitizer struct {
+	allow map[string]map[string]bool // allowed tags and their attributes
+}
+
+// Tag holds the parts of an HTML tag.
+type Tag struct {
+	Name    string     // lower case name of the tag
+	Attrs   [][]string // attribute names and their unescaped values
+	Closing bool       // true for a closing tag
+	Empty   bool       // true for a tag ending with '/>'
+}
+
+// NewSanitizer returns a pointer to a new Sanitizer which allows the tags, and
+// their attributes, in 'allow'. When 'allow' is nil, DefaultAllow is used.
+func NewSanitizer(allow map[string][]string) *Sanitizer {
+	if allow == nil {
+		allow = DefaultAllow
+	}
+	sn := &Sanitizer{allow: map[string]map[string]bool{}}
+	for tg, attrs := range allow {
+		a := map[string]bool{}
+		for _, at := range attrs {
+			a[strings.ToLower(at)] = true
+		}
+		sn.allow[strings.ToLower(tg)] = a
+	}
+	return sn
+}
+
+// ParseAllow parses an allow-list in the format "tag[:attr...],...", like
+// "b,i,a:href:title".
+func ParseAllow(s string) map[string][]string {
+	allow := map[string][]string{}
+	for _, t := range strings.Split(s, ",") {
+		f := strings.Split(strings.TrimSpace(t), ":")
+		if len(f[0]) > 0 {
+			allow[f[0]] = append(allow[f[0]], f[1:]...)
+		}
+	}
+	return allow
+}
+
+// blockTags holds the names of the tags that start an HTML block which ends
+// with an empty line.
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "base": true,
+	"basefont": true, "blockquote": true, "body": true, "caption": true,
+	"center": true, "col": true, "colgroup": true, "dd": true, "details": true,
+	"dialog": true, "dir": true, "div": true, "dl": true, "dt": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"form": true, "frame": true, "frameset": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "head": true,
+	"header": true, "hr": true, "html": true, "iframe": true, "legend": true,
+	"li": true, "link": true, "main": true, "menu": true, "menuitem": true,
+	"nav": true, "noframes": true, "ol": true, "optgroup": true,
+	"option": true, "p": true, "param": true, "search": true, "section": true,
+	"summary": true, "table": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "title": true, "tr": true, "track": true,
+	"ul": true,
+}
+
+// rawTags holds the names of the tags whose content isn't markdown.
+var rawTags = []string{"pre", "script", "style", "textarea"}
+
+// HTMLBlockStart returns the CommonMark start condition (1 to 7) of the HTML
+// block starting with the line 's', or 0 when 's' doesn't start one. When
+// 'inPara' is true, the block would interrupt a paragraph, which condition 7
+// can't.
+func HTMLBlockStart(s string, inPara bool) int {
+	if len(s) < 2 || s[0] != '<' {
+		return 0
+	}
+
+	ls := strings.ToLower(s)
+	for _, tg := range rawTags {
+		if strings.HasPrefix(ls[1:], tg) {
+			if n := len(tg) + 1; n == len(s) || strings.IndexByte(" \t>", s[n]) >= 0 {
+				return 1
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(s, "<!--"):
+		return 2
+	case strings.HasPrefix(s, "<?"):
+		return 3
+	case strings.HasPrefix(s, "<![CDATA["):
+		return 5
+	case strings.HasPrefix(s, "<!") && len(s) > 2 && isAlpha(s[2]):
+		return 4
+	}
+
+	i := 1
+	if s[i] == '/' {
+		i++
+	}
+	if n := tagNameLen(s[i:]); n > 0 && blockTags[ls[i:i+n]] {
+		i += n
+		if i == len(s) || strings.IndexByte(" \t>", s[i]) >= 0 ||
+			strings.HasPrefix(s[i:], "/>") {
+			return 6
+		}
+	}
+
+	if t, n := ParseTag(s); n > 0 && !inPara &&
+		len(strings.TrimSpace(s[n:])) <= 0 {
+		for _, tg := range rawTags {
+			if t.Name == tg {
+				return 0
+			}
+		}
+		return 7
+	}
+	return 0
+}
+
+// HTMLBlockEnds tests if the line 's' ends an HTML block with the start
+// condition 'cond'. Blocks with conditions 6 and 7 end with an empty line,
+// which isn't part of the block.
+func HTMLBlockEnds(s string, cond int) bool {
+	switch cond {
+	case 1:
+		ls := strings.ToLower(s)
+		for _, tg := range rawTags {
+			if strings.Contains(ls, "</"+tg+">") {
+				return true
+			}
+		}
+		return false
+	case 2:
+		return strings.Contains(s, "-->")
+	case 3:
+		return strings.Contains(s, "?>")
+	case 4:
+		return strings.Contains(s, ">")
+	case 5:
+		return strings.Contains(s, "]]>")
+	}
+	return len(strings.TrimSpace(s)) <= 0
+}
+
+// HTMLTagLen returns the length of the HTML tag, comment, processing
+// instruction, declaration or CDATA section at the start of 's', or 0 when
+// there is none.
+func HTMLTagLen(s string) int {
+	for _, d := range [][]string{
+		{"<!--", "-->"}, {"<?", "?>"}, {"<![CDATA[", "]]>"},
+	} {
+		if strings.HasPrefix(s, d[0]) {
+			if i := strings.Index(s[len(d[0]):], d[1]); i >= 0 {
+				return len(d[0]) + i + len(d[1])
+			}
+			return 0
+		}
+	}
+	if strings.HasPrefix(s, "<!") && len(s) > 2 && isAlpha(s[2]) {
+		return strings.IndexByte(s, '>') + 1
+	}
+	if _, n := ParseTag(s); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// StripTags removes all HTML tags and comments from 's'.
+func StripTags(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if n := HTMLTagLen(s[i:]); n > 0 {
+			i += n - 1
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ParseTag parses the HTML tag at the start of 's'. It returns the tag and
+// its length, or an empty tag and 0 when 's' doesn't start with a tag.
+func ParseTag(s string) (Tag, int) {
+	var t Tag
+	if len(s) < 3 || s[0] != '<' {
+		return Tag{}, 0
+	}
+
+	i := 1
+	if s[i] == '/' {
+		t.Closing = true
+		i++
+	}
+	n := tagNameLen(s[i:])
+	if n <= 0 {
+		return Tag{}, 0
+	}
+	t.Name = strings.ToLower(s[i : i+n])
+	i += n
+
+	for {
+		sp := len(s[i:]) - len(strings.TrimLeft(s[i:], " \t\n"))
+		i += sp
+		switch {
+		case i >= len(s):
+			return Tag{}, 0
+		case s[i] == '>':
+			return t, i + 1
+		case !t.Closing && strings.HasPrefix(s[i:], "/>"):
+			t.Empty = true
+			return t, i + 2
+		case t.Closing || sp == 0:
+			return Tag{}, 0
+		}
+
+		name, value, n := parseAttr(s[i:])
+		if n <= 0 {
+			return Tag{}, 0
+		}
+		t.Attrs = append(t.Attrs, []string{name, value})
+		i += n
+	}
+}
+
+// parseAttr parses the attribute at the start of 's'. It returns its lower
+// case name, its unescaped value and its length, or a length of 0 when 's'
+// doesn't start with an attribute.
+func parseAttr(s string) (name, value string, n int) {
+	for n < len(s) && (isAlnum(s[n]) || strings.IndexByte("_:.-", s[n]) >= 0) {
+		if n == 0 && (isDigit(s[n]) || s[n] == '.' || s[n] == '-') {
+			return "", "", 0
+		}
+		n++
+	}
+	if n == 0 {
+		return "", "", 0
+	}
+	name = strings.ToLower(s[:n])
+
+	rest := strings.TrimLeft(s[n:], " \t\n")
+	if len(rest) == 0 || rest[0] != '=' {
+		return name, "", n
+	}
+	i := len(s) - len(rest) + 1
+	i += len(s[i:]) - len(strings.TrimLeft(s[i:], " \t\n"))
+	if i >= len(s) {
+		return "", "", 0
+	}
+
+	switch q := s[i]; q {
+	case '"', '\'':
+		j := strings.IndexByte(s[i+1:], q)
+		if j < 0 {
+			return "", "", 0
+		}
+		return name, html.UnescapeString(s[i+1 : i+1+j]), i + j + 2
+	default:
+		j := i
+		for j < len(s) && strings.IndexByte(" \t\n\"'=<>`", s[j]) < 0 {
+			j++
+		}
+		if j == i {
+			return "", "", 0
+		}
+		return name, html.UnescapeString(s[i:j]), j
+	}
+}
+
+// tagNameLen returns the length of the tag name at the start of 's'.
+func tagNameLen(s string) int {
+	if len(s) == 0 || !isAlpha(s[0]) {
+		return 0
+	}
+	n := 1
+	for n < len(s) && (isAlnum(s[n]) || s[n] == '-') {
+		n++
+	}
+	return n
+}
+
+// String returns the HTML code for the tag.
+func (t Tag) String() string {
+	if t.Closing {
+		return "</" + t.Name + ">"
+	}
+	s := "<" + t.Name
+	for _, a := range t.Attrs {
+		s = s + " " + a[0] + "=\"" + html.EscapeString(a[1]) + "\""
+	}
+	if t.Empty {
+		return s + "/>"
+	}
+	return s + ">"
+}
+
+// Tag returns the sanitised version of the raw HTML tag or comment 'raw'.
+// Comments are removed and tags that aren't allowed are escaped, so they show
+// up as text. So are processing instructions, declarations and CDATA
+// sections. Attributes that aren't allowed, or that hold an unsafe URL, are
+// removed from allowed tags.
+func (sn *Sanitizer) Tag(raw string) string {
+	if sn == nil {
+		return raw
+	}
+	if strings.HasPrefix(raw, "<!--") {
+		return ""
+	}
+
+	t, n := ParseTag(raw)
+	attrs, ok := sn.allow[t.Name]
+	if n <= 0 || !ok {
+		return html.EscapeString(raw)
+	}
+
+	allowed := [][]string{}
+	for _, a := range t.Attrs {
+		if attrs[a[0]] && (!urlAttrs[a[0]] || sn.URL(a[1])) {
+			allowed = append(allowed, a)
+		}
+	}
+	t.Attrs = allowed
+	return t.String()
+}
+
+// URL tests if the URL 'u' is safe to use in a link or an image, that is if
+// it doesn't use the 'javascript', 'vbscript' or 'data' scheme.
+func (sn *Sanitizer) URL(u string) bool {
+	if sn == nil {
+		return true
+	}
+
+	// browsers ignore control characters and white space in the scheme
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, html.UnescapeString(u))
+
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return true // relative URL
+	}
+	switch strings.ToLower(u[:i]) {
+	case "javascript", "vbscript", "data":
+		return false
+	}
+	return true
+}
+
+func isAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isAlnum(c byte) bool {
+	return isAlpha(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,405 @@
+//
+// tools.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// tools for the markdown package.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// IsEscaped tests if the rune at position 'i' in 's' is escaped by a '\'.
+func IsEscaped(s string, i int) bool {
+	n := 0
+	for i-n > 0 && s[i-n-1] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+// HardBreak returns the marker for a hard line break at the end of the line
+// 's': two or more spaces or an unescaped backslash. When there is no hard
+// line break, an empty string is returned.
+func HardBreak(s string) string {
+	s = strings.TrimRight(s, "\r\n")
+	switch l := len(s); {
+	case len(strings.TrimSpace(s)) <= 0:
+		return ""
+	case strings.HasSuffix(s, "  "):
+		return s[len(strings.TrimRight(s, " ")):]
+	case s[l-1] == '\\' && !IsEscaped(s, l-1):
+		return "\\"
+	}
+	return ""
+}
+
+// IsBullet tests if 's' starts with the marker of an unordered list item: a
+// '-', '+' or '*' followed by white space.
+func IsBullet(s string) bool {
+	return len(s) > 0 && strings.IndexByte("-+*", s[0]) >= 0 &&
+		(len(s) == 1 || s[1] == ' ' || s[1] == '\t')
+}
+
+// IsDefMarker tests if 's' starts with the marker of a definition in a
+// definition list: a ':' followed by white space and the definition.
+func IsDefMarker(s string) bool {
+	return len(s) > 2 && s[0] == ':' && (s[1] == ' ' || s[1] == '\t') &&
+		len(strings.TrimSpace(s[2:])) > 0
+}
+
+// ContentIndent returns the indent of the contents of a list item or a
+// definition in the line 's', where its marker ends at position 'w'. The
+// contents follow the marker after 1 to 4 spaces.
+func ContentIndent(s string, w int) int {
+	n := CountLeading(s[w:], ' ', -1)
+	if n > 4 || len(strings.TrimSpace(s[w:])) <= 0 {
+		n = 1
+	}
+	return w + n
+}
+
+// OrderedListMarker returns the length of the number of the ordered list item
+// marker at the start of 's', or 0 when there is none. The number has 1 to 9
+// digits and is followed by a '.' or ')' and white space.
+func OrderedListMarker(s string) int {
+	n := 0
+	for n < len(s) && n <= 9 && isDigit(s[n]) {
+		n++
+	}
+	switch {
+	case n <= 0 || n > 9 || n >= len(s) || s[n] != '.' && s[n] != ')':
+		return 0
+	case n+1 < len(s) && s[n+1] != ' ' && s[n+1] != '\t':
+		return 0
+	}
+	return n
+}
+
+// TaskBox returns the HTML code for the check box of a task list item whose
+// text 's' starts with "[ ]", "[x]" or "[X]" followed by white space and more
+// text. Otherwise "" is returned.
+func TaskBox(s string) string {
+	if len(s) < 5 || s[0] != '[' || s[2] != ']' || s[3] != ' ' && s[3] != '\t' ||
+		len(strings.TrimSpace(s[4:])) <= 0 {
+		return ""
+	}
+	switch s[1] {
+	case ' ':
+		return cTaskBox
+	case 'x', 'X':
+		return cTaskBoxChecked
+	}
+	return ""
+}
+
+// IsPunctuation tests if 'c' is an ASCII punctuation rune. These can be
+// escaped by a '\'.
+func IsPunctuation(c byte) bool {
+	return c >= '!' && c <= '/' || c >= ':' && c <= '@' ||
+		c >= '[' && c <= '`' || c >= '{' && c <= '~'
+}
+
+// IsClosingFence tests if 's' closes fenced code that was opened by 'fence'.
+// It must have an indent of at most three spaces and hold a fence of the same
+// runes, that is at least as long as 'fence'.
+func IsClosingFence(s, fence string) bool {
+	if len(fence) <= 0 || CountLeading(s, ' ', -1) > 3 {
+		return false
+	}
+	s = strings.TrimSpace(s)
+	return len(s) >= len(fence) && OnlyRunes(s, rune(fence[0]))
+}
+
+// AlertType returns the type of the alert marker "[!TYPE]" in 's' in lower
+// case. The type is "NOTE", "TIP", "IMPORTANT", "WARNING" or "CAUTION", in any
+// case. When 's' isn't an alert marker, "" is returned.
+func AlertType(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 4 || !strings.HasPrefix(s, "[!") || s[len(s)-1] != ']' {
+		return ""
+	}
+	switch typ := strings.ToLower(s[2 : len(s)-1]); typ {
+	case "note", "tip", "important", "warning", "caution":
+		return typ
+	}
+	return ""
+}
+
+// SplitAdmonition splits 's' into an opening fence for an admonition, its type
+// and its title. A fence has at least three ':' runes and is followed by the
+// type, which holds letters, digits and '-' runes. When there is no title,
+// the type starting with a capital is used. If 's' doesn't start with a fence
+// and a type, three empty strings are returned.
+func SplitAdmonition(s string) (fence, typ, title string) {
+	n := CountLeading(s, ':', -1)
+	if n < 3 {
+		return "", "", ""
+	}
+	info := strings.TrimSpace(s[n:])
+	m := 0
+	for m < len(info) && (isDigit(info[m]) || info[m] == '-' ||
+		'a' <= info[m] && info[m] <= 'z' || 'A' <= info[m] && info[m] <= 'Z') {
+		m++
+	}
+	if m <= 0 || m < len(info) && info[m] != ' ' && info[m] != '\t' {
+		return "", "", ""
+	}
+	typ = strings.ToLower(info[:m])
+	title = strings.TrimSpace(info[m:])
+	if len(title) <= 0 {
+		title = strings.ToUpper(typ[:1]) + typ[1:]
+	}
+	return s[:n], typ, title
+}
+
+// IsAdmonition tests if 's' starts with a fence for an admonition.
+func IsAdmonition(s string) bool {
+	fence, _, _ := SplitAdmonition(s)
+	return len(fence) > 0
+}
+
+// IsMathBlock tests if 's' starts a block of display math: it starts with
+// "$$" that isn't closed on the same line.
+func IsMathBlock(s string) bool {
+	return strings.HasPrefix(s, "$$") && !strings.Contains(s[2:], "$$")
+}
+
+// IsFence tests if 's' starts with a fence for fenced code.
+func IsFence(s string) bool {
+	fence, _ := SplitFence(s)
+	return len(fence) > 0
+}
+
+// IsThematicBreak tests if 's', apart from leading and trailing white space,
+// holds at least three '-', '_' or '*' runes, all the same, optionally
+// separated by spaces or tabs.
+func IsThematicBreak(s string) bool {
+	s = strings.TrimSpace(s)
+	if len(s) < 3 {
+		return false
+	}
+
+	rn := s[0]
+	if rn != '-' && rn != '_' && rn != '*' {
+		return false
+	}
+
+	n := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case rn:
+			n++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+// SplitFence splits 's' into an opening fence for fenced code and the info
+// string following it. A fence has at least three '`' or '~' runes. The info
+// string for a '`' fence must not contain a '`'. If 's' doesn't start with a
+// fence, two empty strings are returned.
+func SplitFence(s string) (fence, info string) {
+	if len(s) < 3 || (s[0] != '`' && s[0] != '~') {
+		return "", ""
+	}
+
+	n := CountLeading(s, rune(s[0]), -1)
+	if n == 0 {
+		n = len(s) // 's' holds nothing but the fence
+	}
+	if n < 3 {
+		return "", ""
+	}
+
+	info = strings.TrimSpace(s[n:])
+	if s[0] == '`' && strings.Contains(info, "`") {
+		return "", ""
+	}
+	return s[:n], info
+}
+
+// StripIndent removes at most 'n' leading spaces from 's'.
+func StripIndent(s string, n int) string {
+	for n > 0 && len(s) > 0 && s[0] == ' ' {
+		s = s[1:]
+		n--
+	}
+	return s
+}
+
+// OnlyRunes tests if a string of at least three runes 'rn' and no other runes.
+func OnlyRunes(s string, rn rune) bool {
+	if len(s) < 3 {
+		return false
+	}
+	for _, r := range s {
+		if r != rn {
+			return false
+		}
+	}
+	return true
+}
+
+// CountLeading returns the number of leading runes 'rn' in string 's'. 'm'
+// is the maximum that is alowed. When 'm' is less than 0, unlimited runes are
+// alowed.
+func CountLeading(s string, rn rune, m int) int {
+	n := 0
+	for _, r := range s {
+		switch r {
+		case rn:
+			n++
+			if m >= 0 && n > m {
+				return 0
+			}
+		default:
+			return n
+		}
+	}
+	return 0
+}
+
+// EscapeHTML escapes the runes '<', '>', '&' and '"' in 's'.
+func EscapeHTML(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '&':
+			b.WriteString("&amp;")
+		case '"':
+			b.WriteString("&#34;")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// Entity resolves the entity or numeric character reference at the start of
+// 's'. It returns the text for it and its length. When there is no valid
+// reference, the length is 0. Named entities must be in the HTML5 table,
+// numeric references to 0, to surrogates or beyond U+10FFFF give U+FFFD.
+func Entity(s string) (string, int) {
+	n := EntityLen(s)
+	switch {
+	case n <= 0:
+		return "", 0
+	case s[1] != '#':
+		r := html.UnescapeString(s[:n])
+		if r == s[:n] || strings.HasSuffix(r, ";") && s[:n] != "&semi;" {
+			// unknown, or only a legacy entity like "&not" at its start
+			return "", 0
+		}
+		return r, n
+	}
+
+	base, digits := 10, s[2:n-1]
+	if s[2] == 'x' || s[2] == 'X' {
+		base, digits = 16, s[3:n-1]
+	}
+	cp, err := strconv.ParseUint(digits, base, 32)
+	if err != nil || cp == 0 || cp > unicode.MaxRune || cp >= 0xd800 && cp <= 0xdfff {
+		return string(unicode.ReplacementChar), n
+	}
+	return string(rune(cp)), n
+}
+
+// EntityLen returns the length of the character reference, like "&amp;",
+// "&#123;" or "&#x7b;", at the start of 's', or 0 when there is none.
+func EntityLen(s string) int {
+	if len(s) < 3 || s[0] != '&' {
+		return 0
+	}
+	i, max := 1, 32
+	isValid := isAlnum
+	if s[1] == '#' {
+		i, max = 2, 7
+		isValid = isDigit
+		if s[2] == 'x' || s[2] == 'X' {
+			i, max = 3, 6
+			isValid = isHexDigit
+		}
+	}
+	n := 0
+	for i+n < len(s) && n < max && isValid(s[i+n]) {
+		n++
+	}
+	if n == 0 || i+n >= len(s) || s[i+n] != ';' {
+		return 0
+	}
+	return i + n + 1
+}
+
+// SplitCells splits the table row 's' at each '|' that isn't escaped.
+func SplitCells(s string) []string {
+	cells := []string{}
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '|' && !IsEscaped(s, i) {
+			cells = append(cells, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(cells, s[start:])
+}
+
+// Unescape removes the '\' from all escaped ASCII punctuation runes in 's' and
+// resolves its entity and numeric character references.
+func Unescape(s string) string {
+	if !strings.ContainsAny(s, "\\&") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && IsPunctuation(s[i+1]):
+			i++
+		case s[i] == '&':
+			if r, n := Entity(s[i:]); n > 0 {
+				b.WriteString(r)
+				i += n - 1
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,511 @@
+//
+// mathml.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// mathml converts TeX math to MathML.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package mathml converts a subset of TeX math to MathML, which browsers
+// render without JavaScript or fonts from the network.
+package mathml
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const xmlns = "http://www.w3.org/1998/Math/MathML"
+
+// Convert returns the MathML code for the TeX math 'tex'. When 'display' is
+// true, it is displayed as a block and the limits of operators like \sum are
+// written below and above them. Unknown commands show up as errors.
+func Convert(tex string, display bool) string {
+	p := parser{s: tex, display: display}
+	attr := ""
+	if display {
+		attr = " display=\"block\""
+	}
+	return "<math xmlns=\"" + xmlns + "\"" + attr + ">" + mrow(p.all()) + "</math>"
+}
+
+// parser parses the TeX math in 's' from position 'i'.
+type parser struct {
+	s       string
+	i       int
+	display bool
+}
+
+// all parses the elements up to the end of the math. Unmatched closing braces,
+// column and row separators, \end and \right are skipped.
+func (p *parser) all() []string {
+	r := p.row()
+	for p.i < len(p.s) {
+		p.skipEnd()
+		r = append(r, p.row()...)
+	}
+	return r
+}
+
+// row parses elements up to the end of the math, a closing brace, a column
+// or row separator, \end or \right.
+func (p *parser) row() []string {
+	r := []string{}
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) || p.atEnd() {
+			return r
+		}
+		base, lim := p.atom()
+		r = append(r, p.scripts(base, lim))
+	}
+}
+
+// atEnd tests if a closing brace, a column or row separator, \end or \right
+// starts at the current position.
+func (p *parser) atEnd() bool {
+	s := p.s[p.i:]
+	return s[0] == '}' || s[0] == '&' || strings.HasPrefix(s, "\\\\") ||
+		p.isCommand("end") || p.isCommand("right")
+}
+
+// isCommand tests if the command 'name' starts at the current position.
+func (p *parser) isCommand(name string) bool {
+	s := p.s[p.i:]
+	return strings.HasPrefix(s, "\\"+name) &&
+		(len(s) == len(name)+1 || !isLetter(s[len(name)+1]))
+}
+
+// skipEnd skips the closing brace, column or row separator, \end{...} or
+// \right... at the current position.
+func (p *parser) skipEnd() {
+	switch {
+	case strings.HasPrefix(p.s[p.i:], "\\\\"):
+		p.i += 2
+	case p.isCommand("end"):
+		p.i += len("\\end")
+		p.text()
+	case p.isCommand("right"):
+		p.i += len("\\right")
+		p.delimiter()
+	default:
+		p.i++
+	}
+}
+
+// skipSpace skips the white space at the current position.
+func (p *parser) skipSpace() {
+	for p.i < len(p.s) && isSpace(p.s[p.i]) {
+		p.i++
+	}
+}
+
+// atom parses a single element without its scripts. 'lim' is true when its
+// scripts are limits.
+func (p *parser) atom() (e string, lim bool) {
+	c := p.s[p.i]
+	switch {
+	case c == '{':
+		p.i++
+		r := p.row()
+		if p.i < len(p.s) && p.s[p.i] == '}' {
+			p.i++
+		}
+		return mrow(r), false
+
+	case c == '\\':
+		return p.command()
+
+	case c == '^' || c == '_':
+		// scripts without a base
+		return "<mrow></mrow>", false
+
+	case isLetter(c):
+		p.i++
+		return elem("mi", string(c)), false
+
+	case isDigit(c) || c == '.' && p.i+1 < len(p.s) && isDigit(p.s[p.i+1]):
+		n := p.i
+		for p.i < len(p.s) && (isDigit(p.s[p.i]) || p.s[p.i] == '.') {
+			p.i++
+		}
+		return elem("mn", p.s[n:p.i]), false
+	}
+
+	r, n := utf8.DecodeRuneInString(p.s[p.i:])
+	p.i += n
+	if unicode.IsLetter(r) {
+		return elem("mi", string(r)), false
+	}
+	return elem("mo", string(r)), false
+}
+
+// scripts parses the subscript, superscript and primes following the element
+// 'base'. When 'lim' is true and the math is displayed, they are written
+// below and above it. A double subscript or superscript gives an error.
+func (p *parser) scripts(base string, lim bool) string {
+	start := p.i
+	sub := ""
+	sup := []string{}
+	double, hasSup := false, false
+loop:
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			break
+		}
+		switch p.s[p.i] {
+		case '_':
+			p.i++
+			double = double || len(sub) > 0
+			sub = p.arg()
+		case '^':
+			p.i++
+			double = double || hasSup
+			hasSup = true
+			sup = append(sup, p.arg())
+		case '\'':
+			p.i++
+			sup = append(sup, elem("mo", "′"))
+		default:
+			break loop
+		}
+	}
+
+	if double {
+		src := strings.TrimSpace(p.s[start:p.i])
+		return "<mrow>" + base + "<merror><mtext>" + html.EscapeString(src) +
+			"</mtext></merror></mrow>"
+	}
+
+	tags := []string{"msub", "msup", "msubsup"}
+	if lim && p.display {
+		tags = []string{"munder", "mover", "munderover"}
+	}
+	switch {
+	case len(sub) > 0 && len(sup) > 0:
+		return "<" + tags[2] + ">" + base + sub + mrow(sup) + "</" + tags[2] + ">"
+	case len(sub) > 0:
+		return "<" + tags[0] + ">" + base + sub + "</" + tags[0] + ">"
+	case len(sup) > 0:
+		return "<" + tags[1] + ">" + base + mrow(sup) + "</" + tags[1] + ">"
+	}
+	return base
+}
+
+// arg parses the argument of a command or a script: a group, a single digit
+// or another single element.
+func (p *parser) arg() string {
+	p.skipSpace()
+	if p.i >= len(p.s) || p.atEnd() {
+		return "<mrow></mrow>"
+	}
+	if c := p.s[p.i]; isDigit(c) {
+		p.i++
+		return elem("mn", string(c))
+	}
+	e, _ := p.atom()
+	return e
+}
+
+// name parses the name of the command following a '\': its letters or a
+// single other rune.
+func (p *parser) name() string {
+	n := p.i
+	for p.i < len(p.s) && isLetter(p.s[p.i]) {
+		p.i++
+	}
+	if p.i == n && p.i < len(p.s) {
+		p.i++
+	}
+	return p.s[n:p.i]
+}
+
+// text parses a group holding text, like the argument of \text. It returns
+// the text without the braces.
+func (p *parser) text() string {
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return ""
+	}
+	if p.s[p.i] != '{' {
+		p.i++
+		return p.s[p.i-1 : p.i]
+	}
+	n := p.i + 1
+	for depth := 0; p.i < len(p.s); p.i++ {
+		switch p.s[p.i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth <= 0 {
+				p.i++
+				return p.s[n : p.i-1]
+			}
+		}
+	}
+	return p.s[n:]
+}
+
+// delimiter parses the delimiter following commands like \left, \right and
+// \big. A '.' is an empty delimiter.
+func (p *parser) delimiter() string {
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return ""
+	}
+	if p.s[p.i] == '\\' {
+		p.i++
+		switch name := p.name(); name {
+		case "{", "}":
+			return name
+		case "|":
+			return "‖"
+		default:
+			return operators[name]
+		}
+	}
+	r, n := utf8.DecodeRuneInString(p.s[p.i:])
+	p.i += n
+	if r == '.' {
+		return ""
+	}
+	return string(r)
+}
+
+// command parses the command following a '\' at the current position. 'lim'
+// is true when the scripts of the element are limits.
+func (p *parser) command() (e string, lim bool) {
+	p.i++
+	name := p.name()
+
+	if s, ok := greek[name]; ok {
+		if unicode.IsUpper(rune(name[0])) {
+			return "<mi mathvariant=\"normal\">" + s + "</mi>", false
+		}
+		return elem("mi", s), false
+	}
+	if s, ok := identifiers[name]; ok {
+		return elem("mi", s), false
+	}
+	if s, ok := operators[name]; ok {
+		return elem("mo", s), false
+	}
+	if s, ok := largeOps[name]; ok {
+		return elem("mo", s), true
+	}
+	if s, ok := integrals[name]; ok {
+		return elem("mo", s), false
+	}
+	if functions[name] {
+		return elem("mi", name), false
+	}
+	if limits[name] {
+		return elem("mi", name), true
+	}
+	if w, ok := spaces[name]; ok {
+		return "<mspace width=\"" + w + "\"/>", false
+	}
+	if s, ok := accents[name]; ok {
+		return "<mover accent=\"true\">" + p.arg() + elem("mo", s) + "</mover>", false
+	}
+	if v, ok := variants[name]; ok {
+		return p.variant(v), false
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num := p.arg()
+		return "<mfrac>" + num + p.arg() + "</mfrac>", false
+
+	case "binom":
+		n := p.arg()
+		return "<mrow><mo>(</mo><mfrac linethickness=\"0\">" + n + p.arg() +
+			"</mfrac><mo>)</mo></mrow>", false
+
+	case "sqrt":
+		p.skipSpace()
+		if p.i < len(p.s) && p.s[p.i] == '[' {
+			if j := strings.IndexByte(p.s[p.i:], ']'); j > 0 {
+				q := parser{s: p.s[p.i+1 : p.i+j], display: p.display}
+				p.i += j + 1
+				index := mrow(q.all())
+				return "<mroot>" + p.arg() + index + "</mroot>", false
+			}
+		}
+		return "<msqrt>" + p.arg() + "</msqrt>", false
+
+	case "underline":
+		return "<munder accent=\"true\">" + p.arg() + elem("mo", "_") + "</munder>", false
+
+	case "text", "textrm", "textit", "textbf", "mbox":
+		return elem("mtext", p.text()), false
+
+	case "operatorname":
+		return elem("mi", p.text()), false
+
+	case "left":
+		open := p.delimiter()
+		r := p.row()
+		close := ""
+		if p.isCommand("right") {
+			p.i += len("\\right")
+			close = p.delimiter()
+		}
+		return fenced(open, r, close), false
+
+	case "big", "Big", "bigg", "Bigg", "bigl", "Bigl", "biggl", "Biggl",
+		"bigr", "Bigr", "biggr", "Biggr":
+		return elem("mo", p.delimiter()), false
+
+	case "begin":
+		return p.environment(p.text()), false
+
+	case "{", "}", "%", "$", "&", "#", "_":
+		return elem("mo", name), false
+
+	case "|":
+		return elem("mo", "‖"), false
+
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return "", false
+	}
+
+	return "<merror><mtext>\\" + html.EscapeString(name) + "</mtext></merror>", false
+}
+
+// variant parses the argument of a font command like \mathbf and gives its
+// identifiers the math variant 'v'.
+func (p *parser) variant(v string) string {
+	e := p.arg()
+	if v == "double-struck" {
+		for c, s := range doubleStruck {
+			e = strings.Replace(e, elem("mi", string(c)), elem("mi", s), -1)
+		}
+	}
+	return strings.Replace(e, "<mi>", "<mi mathvariant=\""+v+"\">", -1)
+}
+
+// environment parses the rows of the environment 'name', like a matrix, up to
+// its \end. Cells are separated by a '&' and rows by a '\\'.
+func (p *parser) environment(name string) string {
+	if name == "array" {
+		p.text() // column specification
+	}
+
+	rows := [][]string{}
+	cells := []string{}
+	for {
+		cells = append(cells, mrow(p.row()))
+		switch {
+		case p.i >= len(p.s):
+		case p.s[p.i] == '&':
+			p.i++
+			continue
+		case strings.HasPrefix(p.s[p.i:], "\\\\"):
+			p.i += 2
+			rows = append(rows, cells)
+			cells = []string{}
+			continue
+		case p.isCommand("end"):
+			p.skipEnd()
+		default:
+			// an unmatched closing brace or \right
+			p.skipEnd()
+			continue
+		}
+		break
+	}
+	if len(cells) > 1 || cells[0] != "<mrow></mrow>" {
+		rows = append(rows, cells)
+	}
+
+	var b strings.Builder
+	b.WriteString("<mtable")
+	switch name {
+	case "cases":
+		b.WriteString(" columnalign=\"left\"")
+	case "align", "align*", "aligned":
+		b.WriteString(" columnalign=\"right left\"")
+	}
+	b.WriteString(">")
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+		for _, cell := range row {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+
+	f := fences[name]
+	return fenced(f[0], []string{b.String()}, f[1])
+}
+
+// fenced returns the elements 'r' between the delimiters 'open' and 'close',
+// which may be empty.
+func fenced(open string, r []string, close string) string {
+	if len(open) <= 0 && len(close) <= 0 {
+		return mrow(r)
+	}
+	f := []string{}
+	if len(open) > 0 {
+		f = append(f, elem("mo", open))
+	}
+	f = append(f, r...)
+	if len(close) > 0 {
+		f = append(f, elem("mo", close))
+	}
+	return "<mrow>" + strings.Join(f, "") + "</mrow>"
+}
+
+// mrow returns the elements 'r' as a single element.
+func mrow(r []string) string {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return "<mrow>" + strings.Join(r, "") + "</mrow>"
+}
+
+// elem returns the element 'tag' holding the text 's'.
+func elem(tag, s string) string {
+	return "<" + tag + ">" + html.EscapeString(s) + "</" + tag + ">"
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,91 @@
+//
+// mathml_test.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// tests for the highlight package.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package mathml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		tex     string
+		display bool
+		want    string
+	}{
+		{tex: "x", want: "<mi>x</mi>"},
+		{tex: "x_i^2", want: "<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>"},
+		{tex: "x^{10}", want: "<msup><mi>x</mi><mn>10</mn></msup>"},
+		{tex: "x^10", want: "<mrow><msup><mi>x</mi><mn>1</mn></msup><mn>0</mn></mrow>"},
+		{tex: "f'", want: "<msup><mi>f</mi><mo>′</mo></msup>"},
+		{tex: "f'^2", want: "<msup><mi>f</mi><mrow><mo>′</mo><mn>2</mn></mrow></msup>"},
+		{tex: "a_b_c", want: "<mrow><mi>a</mi><merror><mtext>_b_c</mtext></merror></mrow>"},
+		{tex: "x^2^3", want: "<mrow><mi>x</mi><merror><mtext>^2^3</mtext></merror></mrow>"},
+		{tex: "x_1^2_3", want: "<mrow><mi>x</mi><merror><mtext>_1^2_3</mtext></merror></mrow>"},
+		{tex: "3.14 < a", want: "<mrow><mn>3.14</mn><mo>&lt;</mo><mi>a</mi></mrow>"},
+		{tex: "\\frac{a}{b+1}",
+			want: "<mfrac><mi>a</mi><mrow><mi>b</mi><mo>+</mo><mn>1</mn></mrow></mfrac>"},
+		{tex: "\\alpha \\cdot \\Omega",
+			want: "<mrow><mi>α</mi><mo>⋅</mo><mi mathvariant=\"normal\">Ω</mi></mrow>"},
+		{tex: "\\sum_{i=1}^n i",
+			want: "<mrow><msubsup><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup><mi>i</mi></mrow>"},
+		{tex: "\\sum_{i=1}^n", display: true,
+			want: "<munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover>"},
+		{tex: "\\int_0^1", display: true,
+			want: "<msubsup><mo>∫</mo><mn>0</mn><mn>1</mn></msubsup>"},
+		{tex: "\\sqrt[3]{x}", want: "<mroot><mi>x</mi><mn>3</mn></mroot>"},
+		{tex: "\\sin x", want: "<mrow><mi>sin</mi><mi>x</mi></mrow>"},
+		{tex: "\\begin{pmatrix} a & b \\\\ c & d \\end{pmatrix}",
+			want: "<mrow><mo>(</mo><mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr>" +
+				"<mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable><mo>)</mo></mrow>"},
+		{tex: "\\begin{matrix} 1 \\\\ \\end{matrix}",
+			want: "<mtable><mtr><mtd><mn>1</mn></mtd></mtr></mtable>"},
+		{tex: "\\left( x \\right.", want: "<mrow><mo>(</mo><mi>x</mi></mrow>"},
+		{tex: "\\mathbb{R}", want: "<mi mathvariant=\"double-struck\">ℝ</mi>"},
+		{tex: "\\text{if } x", want: "<mrow><mtext>if </mtext><mi>x</mi></mrow>"},
+		{tex: "\\vec{v}", want: "<mover accent=\"true\"><mi>v</mi><mo>→</mo></mover>"},
+		{tex: "a}b", want: "<mrow><mi>a</mi><mi>b</mi></mrow>"},
+		{tex: "\\foo<", want: "<mrow><merror><mtext>\\foo</mtext></merror><mo>&lt;</mo></mrow>"},
+	}
+
+	for _, tst := range tests {
+		got := Convert(tst.tex, tst.display)
+		want := "<math xmlns=\"" + xmlns + "\">" + tst.want + "</math>"
+		if tst.display {
+			want = strings.Replace(want, ">", " display=\"block\">", 1)
+		}
+		if got != want {
+			t.Errorf("Convert(%q) generates:\n%q\nshould be:\n%q\n", tst.tex, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+//
+// symbols.go
+//
+//  Questions and comments to:
+//       <mailto:frank@foef.nl>
+//
+// tables with the TeX commands for symbols.
+//
+// Copyright © 2018 Frank Storbeck. All rights reserved.
+// Code licensed under the BSD License:
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package mathml
+
+import "strings"
+
+// greek holds the Greek letters.
+var greek = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"omicron": "ο", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+}
+
+// identifiers holds the symbols that are identifiers.
+var identifiers = map[string]string{
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅",
+	"varnothing": "∅", "hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ",
+	"aleph": "ℵ", "wp": "℘",
+}
+
+// operators holds the symbols that are operators, relations, arrows or
+// delimiters.
+var operators = map[string]string{
+	"times": "×", "cdot": "⋅", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "otimes": "⊗",
+	"cup": "∪", "cap": "∩", "setminus": "∖", "wedge": "∧", "land": "∧",
+	"vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "in": "∈", "notin": "∉", "ni": "∋",
+	"subset": "⊂", "supset": "⊃", "subseteq": "⊆", "supseteq": "⊇",
+	"perp": "⊥", "parallel": "∥", "mid": "∣", "forall": "∀", "exists": "∃",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺", "mapsto": "↦",
+	"uparrow": "↑", "downarrow": "↓",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "vert": "|", "Vert": "‖", "lbrace": "{",
+	"rbrace": "}", "prime": "′",
+}
+
+// largeOps holds the large operators. Their limits are written below and
+// above them in display math.
+var largeOps = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigvee": "⋁", "bigwedge": "⋀",
+}
+
+// integrals holds the integral signs. Their limits are always scripts.
+var integrals = map[string]string{
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+// functions holds the names of functions like sin, which are written
+// upright.
+var functions = newSet("arccos arcsin arctan arg cos cosh cot coth csc deg " +
+	"det dim exp gcd hom ker lg ln log sec sin sinh tan tanh")
+
+// limits holds the names of functions like lim, with limits below them in
+// display math.
+var limits = newSet("inf lim liminf limsup max min sup")
+
+// accents holds the accents written above their argument.
+var accents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→",
+	"tilde": "~", "widetilde": "~", "dot": "˙", "ddot": "¨",
+	"overrightarrow": "→",
+}
+
+// spaces holds the widths of the spacing commands.
+var spaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	"!": "-0.1667em", " ": "0.25em", "quad": "1em", "qquad": "2em",
+}
+
+// variants holds the math variants for the font commands.
+var variants = map[string]string{
+	"mathbf": "bold", "boldsymbol": "bold-italic", "mathit": "italic",
+	"mathrm": "normal", "mathsf": "sans-serif", "mathtt": "monospace",
+	"mathcal": "script", "mathfrak": "fraktur", "mathbb": "double-struck",
+}
+
+// doubleStruck holds the double struck letters of the usual number sets.
+var doubleStruck = map[byte]string{
+	'C': "ℂ", 'H': "ℍ", 'N': "ℕ", 'P': "ℙ", 'Q': "ℚ", 'R': "ℝ", 'Z': "ℤ",
+}
+
+// fences holds the delimiters that surround each matrix environment.
+var fences = map[string][2]string{
+	"matrix": {"", ""}, "pmatrix": {"(", ")"}, "bmatrix": {"[", "]"},
+	"Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"}, "Vmatrix": {"‖", "‖"},
+	"cases": {"{", ""}, "aligned": {"", ""}, "align": {"", ""},
+	"align*": {"", ""}, "array": {"", ""}, "gathered": {"", ""},
+}
+
+// newSet returns a set holding the space separated words in 's'.
+func newSet(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		set[w] = true
+	}
+	return set
+}